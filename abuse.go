@@ -0,0 +1,206 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Abuse actions applied to a flagged client
+const (
+	abuseActionBlock    = "block"
+	abuseActionThrottle = "throttle"
+)
+
+// honeypotPaths are never linked from the API; only scanners request them
+var honeypotPaths = []string{"/.env", "/.git/config", "/admin.php", "/phpmyadmin", "/wp-login.php"}
+
+// flaggedClient describes a client currently blocked or throttled
+type flaggedClient struct {
+	Client    string    `json:"client"`
+	Reason    string    `json:"reason"`
+	Action    string    `json:"action"`
+	FlaggedAt time.Time `json:"flagged_at"`
+	Until     time.Time `json:"until"`
+}
+
+// clientActivity is the per-client state used to spot scraping
+type clientActivity struct {
+	lastSeen    time.Time
+	windowStart time.Time
+	notFound    int
+	lastID      int
+	sequential  int
+	lastAllowed time.Time
+	flagged     *flaggedClient
+}
+
+// abuseDetector flags clients that enumerate IDs, trip many 404s or probe
+// honeypot paths, then blocks or throttles them until the flag expires or
+// an admin lifts it. Clients are keyed by IP address.
+type abuseDetector struct {
+	cfg       Config
+	mu        sync.Mutex
+	clients   map[string]*clientActivity
+	lastPrune time.Time
+}
+
+func newAbuseDetector(c Config) *abuseDetector {
+	return &abuseDetector{cfg: c, clients: map[string]*clientActivity{}, lastPrune: time.Now()}
+}
+
+// activity returns the state for a client, creating it if needed and
+// clearing an expired flag. The caller must hold d.mu.
+func (d *abuseDetector) activity(client string, now time.Time) *clientActivity {
+	if now.Sub(d.lastPrune) > d.cfg.AbuseWindow {
+		for key, a := range d.clients {
+			if a.flagged == nil && now.Sub(a.lastSeen) > 2*d.cfg.AbuseWindow {
+				delete(d.clients, key)
+			}
+		}
+		d.lastPrune = now
+	}
+
+	a, ok := d.clients[client]
+	if !ok {
+		a = &clientActivity{windowStart: now}
+		d.clients[client] = a
+	}
+	if a.flagged != nil && now.After(a.flagged.Until) {
+		a.flagged = nil
+	}
+	if now.Sub(a.windowStart) > d.cfg.AbuseWindow {
+		a.windowStart = now
+		a.notFound = 0
+	}
+	a.lastSeen = now
+	return a
+}
+
+// flag marks a client as abusive. The caller must hold d.mu.
+func (d *abuseDetector) flag(client string, a *clientActivity, reason string, now time.Time) {
+	if a.flagged != nil {
+		return
+	}
+	a.flagged = &flaggedClient{
+		Client:    client,
+		Reason:    reason,
+		Action:    d.cfg.AbuseAction,
+		FlaggedAt: now.UTC(),
+		Until:     now.Add(d.cfg.AbuseFlagDuration).UTC(),
+	}
+}
+
+// middleware enforces existing flags and inspects each response for new
+// abuse signals
+func (d *abuseDetector) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Admin routes sit behind their own token, and an admin must be able
+		// to unblock a client that shares their address
+		if strings.HasPrefix(c.Request.URL.Path, "/admin/") {
+			c.Next()
+			return
+		}
+
+		client := c.ClientIP()
+		now := time.Now()
+
+		d.mu.Lock()
+		a := d.activity(client, now)
+		if f := a.flagged; f != nil {
+			switch {
+			case f.Action == abuseActionBlock:
+				d.mu.Unlock()
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Client blocked due to suspected abuse"})
+				return
+			case now.Sub(a.lastAllowed) < d.cfg.AbuseThrottleInterval:
+				d.mu.Unlock()
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(d.cfg.AbuseThrottleInterval.Seconds()))))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Client throttled due to suspected abuse"})
+				return
+			}
+			a.lastAllowed = now
+		}
+		d.mu.Unlock()
+
+		c.Next()
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		if c.Writer.Status() == http.StatusNotFound {
+			a.notFound++
+			if a.notFound > d.cfg.AbuseMaxNotFound {
+				d.flag(client, a, "too many 404 responses", now)
+			}
+		}
+
+		// Walking IDs one by one in either direction is a scraping pattern
+		if c.Request.Method == http.MethodGet && c.FullPath() == "/students/:id" {
+			if id, err := parseID(c.Param("id")); err == nil {
+				if a.lastID != 0 && (id == a.lastID+1 || id == a.lastID-1) {
+					a.sequential++
+				} else {
+					a.sequential = 0
+				}
+				a.lastID = id
+				if a.sequential >= d.cfg.AbuseMaxSequential {
+					d.flag(client, a, "sequential ID enumeration", now)
+				}
+			}
+		}
+	}
+}
+
+// honeypot handles requests for paths only scanners would try
+func (d *abuseDetector) honeypot(c *gin.Context) {
+	client := c.ClientIP()
+	now := time.Now()
+
+	d.mu.Lock()
+	d.flag(client, d.activity(client, now), "honeypot "+c.Request.URL.Path, now)
+	d.mu.Unlock()
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+}
+
+// listFlagged handles GET /admin/abuse/clients
+func (d *abuseDetector) listFlagged(c *gin.Context) {
+	now := time.Now()
+
+	d.mu.Lock()
+	flagged := []flaggedClient{}
+	for _, a := range d.clients {
+		if a.flagged != nil && now.Before(a.flagged.Until) {
+			flagged = append(flagged, *a.flagged)
+		}
+	}
+	d.mu.Unlock()
+
+	sort.Slice(flagged, func(i, j int) bool { return flagged[i].FlaggedAt.After(flagged[j].FlaggedAt) })
+	c.JSON(http.StatusOK, gin.H{"clients": flagged})
+}
+
+// unblock handles POST /admin/abuse/clients/:client/unblock
+func (d *abuseDetector) unblock(c *gin.Context) {
+	client := c.Param("client")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	a, ok := d.clients[client]
+	if !ok || a.flagged == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client is not flagged"})
+		return
+	}
+
+	// Start the client over so the history that flagged it doesn't re-flag it
+	delete(d.clients, client)
+	c.JSON(http.StatusOK, gin.H{"message": "Client unblocked"})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hasAdminToken reports whether the request carries the ADMIN_TOKEN bearer
+// token. With no token configured nothing is admin.
+func hasAdminToken(c *gin.Context, token string) bool {
+	given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// adminAuth requires the ADMIN_TOKEN bearer token
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasAdminToken(c, token) {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Admin token required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// registerAdminRoutes mounts the /admin endpoints. They are only served when
+// ADMIN_TOKEN is set, so an instance is never left with open admin routes.
+func (s *server) registerAdminRoutes(router *gin.Engine, abuse *abuseDetector) {
+	if s.cfg.AdminToken == "" {
+		return
+	}
+	admin := router.Group("/admin", adminAuth(s.cfg.AdminToken))
+	admin.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+	admin.GET("/debug/runtime", runtimeStats)
+	admin.PUT("/debug/memory-limit", setMemoryLimit)
+	admin.GET("/debug/heap", heapProfile)
+	admin.POST("/retention/purge", s.triggerPurge)
+	admin.POST("/migrate-email-domain", s.migrateEmailDomain)
+	admin.GET("/toggles", s.getToggles)
+	admin.GET("/export", s.exportStudents)
+	admin.PATCH("/toggles", s.setToggles)
+
+	if s.llmLog != nil {
+		admin.GET("/llm-log", s.llmLog.list)
+		admin.GET("/llm-log/export", s.exportLLMLog)
+	}
+	if s.slo != nil {
+		admin.GET("/slo", s.slo.report)
+	}
+	if abuse != nil {
+		admin.GET("/abuse/clients", abuse.listFlagged)
+		admin.POST("/abuse/clients/:client/unblock", abuse.unblock)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var fakeFirstNames = []string{
+	"Alex", "Amara", "Ben", "Carla", "Daniel", "Elena", "Farah", "George", "Hana", "Ivan",
+	"Julia", "Kofi", "Laura", "Mateo", "Nadia", "Omar", "Priya", "Quinn", "Rosa", "Samuel",
+	"Tara", "Umar", "Vera", "William", "Xin", "Yusuf", "Zoe",
+}
+
+var fakeLastNames = []string{
+	"Adams", "Becker", "Chen", "Diaz", "Evans", "Fischer", "Garcia", "Hughes", "Ito", "Jensen",
+	"Khan", "Lopez", "Moreau", "Nakamura", "Okafor", "Patel", "Rossi", "Silva", "Tanaka", "Ueda",
+	"Vargas", "Walker", "Yilmaz", "Zhang",
+}
+
+// anonymizer maps real values to fake ones. The mapping is keyed by a
+// secret, so it cannot be reversed, and is deterministic within a run, so
+// a value shared by several students maps to the same fake value and
+// duplicate checks behave as before.
+type anonymizer struct {
+	key []byte
+}
+
+// sum hashes a value, with a separate stream per purpose
+func (a anonymizer) sum(purpose, value string) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(purpose + "\x00" + strings.ToLower(strings.TrimSpace(value))))
+	return mac.Sum(nil)
+}
+
+// name returns a fake full name for a real one
+func (a anonymizer) name(real string) string {
+	sum := a.sum("name", real)
+	first := fakeFirstNames[binary.BigEndian.Uint32(sum[0:4])%uint32(len(fakeFirstNames))]
+	last := fakeLastNames[binary.BigEndian.Uint32(sum[4:8])%uint32(len(fakeLastNames))]
+	return first + " " + last
+}
+
+// email returns a fake address for a real one. Emails compare
+// case-insensitively, so case is ignored; the hash suffix keeps distinct
+// addresses distinct.
+func (a anonymizer) email(real string) string {
+	sum := a.sum("email", real)
+	first := fakeFirstNames[binary.BigEndian.Uint32(sum[0:4])%uint32(len(fakeFirstNames))]
+	last := fakeLastNames[binary.BigEndian.Uint32(sum[4:8])%uint32(len(fakeLastNames))]
+	return strings.ToLower(first+"."+last) + "." + hex.EncodeToString(sum[8:12]) + "@example.com"
+}
+
+// runAnonymize implements the anonymize subcommand: it rewrites the name
+// and email of every student in the configured store, in place. It returns
+// the exit code.
+func runAnonymize(args []string) int {
+	flags := flag.NewFlagSet("anonymize", flag.ContinueOnError)
+	yes := flags.Bool("yes", false, "confirm that the configured store should be rewritten")
+	seed := flags.String("seed", "", "secret for the fake value mapping; random if unset, so runs cannot be correlated")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := configure()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if cfg.StoreBackend == "memory" && cfg.SnapshotInterval == 0 {
+		fmt.Fprintln(os.Stderr, "anonymize: STORE_BACKEND is memory without SNAPSHOT_INTERVAL, so there is no stored data to rewrite")
+		return 2
+	}
+	if !*yes {
+		fmt.Fprintf(os.Stderr, "anonymize: this rewrites every student in the %s store in place; run it on the staging copy, with its server stopped for the file, oplog and snapshot backends, and pass -yes\n", cfg.StoreBackend)
+		return 2
+	}
+
+	a := anonymizer{key: []byte(*seed)}
+	if *seed == "" {
+		a.key = make([]byte, 32)
+		if _, err := rand.Read(a.key); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	store, err := openStore(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx := context.Background()
+	list, err := store.List(ctx)
+	if err != nil {
+		store.Close()
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for i, student := range list {
+		student.Name = a.name(student.Name)
+		student.Email = a.email(student.Email)
+		student.Version++
+		if err := store.Update(ctx, student); err != nil {
+			store.Close()
+			fmt.Fprintf(os.Stderr, "anonymize: student %d: %v (%d of %d done)\n", student.ID, err, i, len(list))
+			return 1
+		}
+	}
+
+	// The snapshot backend only writes the rewritten students on Close
+	if err := store.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "anonymize: %v\n", err)
+		return 1
+	}
+	fmt.Printf("anonymized %d student(s) in the %s store\n", len(list), cfg.StoreBackend)
+	return 0
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// updateAll writes every student in after, as a single change. before
+// must hold the same students, in the same order, as they are now. The
+// caller must hold s.mu.
+func (s *server) updateAll(ctx context.Context, before, after []Student) error {
+	_, err := writeBatch(ctx, s.store, studentBatch{updates: after, before: before})
+	return err
+}
+
+// maxBatchSize caps the number of items in one bulk request
+const maxBatchSize = 1000
+
+// batchError is the problem with one item of a bulk request
+type batchError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+	// conflict marks errors that are conflicts with existing students
+	conflict bool
+}
+
+// batchResult is the outcome for one item of a bulk request
+type batchResult struct {
+	Index    int      `json:"index"`
+	ID       any      `json:"id"`
+	Status   string   `json:"status"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// respondBatchErrors refuses a bulk request for the problems with its
+// items: 409 when all of them are conflicts, otherwise 400
+func respondBatchErrors(c *gin.Context, errs []batchError, message string) {
+	status := http.StatusConflict
+	for _, e := range errs {
+		if !e.conflict {
+			status = http.StatusBadRequest
+		}
+	}
+	c.JSON(status, gin.H{"error": message, "errors": errs})
+}
+
+// bindBatch reads a JSON array of 1 to maxBatchSize items, answering 400
+// and returning false if the body is not one
+func bindBatch[T any](c *gin.Context) ([]T, bool) {
+	var items []T
+	if err := c.ShouldBindJSON(&items); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if len(items) == 0 || len(items) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Send between 1 and %d items", maxBatchSize)})
+		return nil, false
+	}
+	return items, true
+}
+
+// createStudents handles POST /students/batch. Each student is validated
+// and checked against the duplicate email policy as by POST /students, and
+// then either all of them are stored or, if any item has a problem, none
+// are and every problem is reported by index.
+func (s *server) createStudents(c *gin.Context) {
+	items, ok := bindBatch[publicStudent](c)
+	if !ok {
+		return
+	}
+
+	var errs []batchError
+	results := make([]batchResult, len(items))
+	students := make([]Student, len(items))
+	for i, item := range items {
+		student := item.Student
+		student.DeletedAt = nil
+		student.Version = 1
+		students[i] = student
+		results[i] = batchResult{Index: i, Status: "created"}
+
+		var problem string
+		if results[i].Warnings, problem = s.validateStudent(student); problem != "" {
+			errs = append(errs, batchError{Index: i, Error: problem})
+		}
+	}
+
+	ctx := c.Request.Context()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Under merge, items whose email is taken update that student instead
+	var mergedBefore, mergedAfter []Student
+	var creates []Student
+	var createIndexes []int
+	if s.cfg.DuplicateEmailPolicy != EmailPolicyAllow {
+		seen := map[string]int{}
+		for i, student := range students {
+			if student.Email == "" {
+				continue
+			}
+			key := strings.ToLower(student.Email)
+			if first, dup := seen[key]; dup {
+				errs = append(errs, batchError{Index: i, Error: fmt.Sprintf("Has the same email as item %d", first), conflict: true})
+				continue
+			}
+			seen[key] = i
+
+			existing, err := s.store.FindByEmail(ctx, student.Email)
+			switch {
+			case errors.Is(err, ErrNotFound):
+				creates = append(creates, student)
+				createIndexes = append(createIndexes, i)
+			case err != nil:
+				respondStoreError(c, err)
+				return
+			case s.cfg.DuplicateEmailPolicy == EmailPolicyReject:
+				errs = append(errs, batchError{Index: i, Error: "A student with this email already exists", conflict: true})
+			case !s.checkEditLock(existing.ID, lockOwner(c)):
+				errs = append(errs, batchError{Index: i, Error: "Merging into this student requires holding its edit lock", conflict: true})
+			default:
+				student.ID = existing.ID
+				student.Version = existing.Version + 1
+				mergedBefore = append(mergedBefore, existing)
+				mergedAfter = append(mergedAfter, student)
+				results[i].ID = publicID(existing.ID)
+				results[i].Status = "merged"
+			}
+		}
+	} else {
+		creates = students
+		for i := range students {
+			createIndexes = append(createIndexes, i)
+		}
+	}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Index < errs[j].Index })
+		respondBatchErrors(c, errs, "Some students have problems; none were stored")
+		return
+	}
+
+	created, err := writeBatch(ctx, s.store, studentBatch{updates: mergedAfter, before: mergedBefore, creates: creates})
+	s.warnQuota(c)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	for j, student := range created {
+		results[createIndexes[j]].ID = publicID(student.ID)
+	}
+	s.invalidateSuggestIndex()
+
+	status := http.StatusCreated
+	if len(created) == 0 {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{"created": len(created), "merged": len(mergedAfter), "results": results})
+}
+
+// batchUpdate is one item of PUT /students/batch: the student's ID and its
+// new values, as PUT /students/:id takes them
+type batchUpdate struct {
+	ID json.RawMessage `json:"id"`
+	Student
+}
+
+// updateStudents handles PUT /students/batch, replacing many students at
+// once. Each item gets the checks of PUT /students/:id: validation, the
+// version if one is sent, the edit lock and the duplicate email policy,
+// which is applied to the emails as they will be after the whole batch.
+// Either every student is updated or, if any item has a problem, none is
+// and every problem is reported by index.
+func (s *server) updateStudents(c *gin.Context) {
+	items, ok := bindBatch[batchUpdate](c)
+	if !ok {
+		return
+	}
+
+	var errs []batchError
+	results := make([]batchResult, len(items))
+	ids := make([]int, len(items))
+	valid := make([]bool, len(items))
+	for i, item := range items {
+		results[i] = batchResult{Index: i, ID: item.ID, Status: "updated"}
+		id, err := parseBodyID(item.ID)
+		if len(item.ID) == 0 || err != nil {
+			errs = append(errs, batchError{Index: i, Error: "Invalid ID"})
+			continue
+		}
+		ids[i] = id
+		student := item.Student
+		var problem string
+		if results[i].Warnings, problem = s.validateStudent(student); problem != "" {
+			errs = append(errs, batchError{Index: i, Error: problem})
+			continue
+		}
+		valid[i] = true
+	}
+
+	ctx := c.Request.Context()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner := lockOwner(c)
+	var before, after []Student
+	inBatch := map[int]int{}
+	for i, item := range items {
+		if !valid[i] {
+			continue
+		}
+		id := ids[i]
+		if first, dup := inBatch[id]; dup {
+			errs = append(errs, batchError{Index: i, Error: fmt.Sprintf("Updates the same student as item %d", first)})
+			continue
+		}
+		inBatch[id] = i
+
+		current, err := s.getLive(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			errs = append(errs, batchError{Index: i, Error: "Student not found"})
+			continue
+		}
+		if err != nil {
+			respondStoreError(c, err)
+			return
+		}
+		if item.Version != 0 && item.Version != current.Version {
+			errs = append(errs, batchError{Index: i, Error: fmt.Sprintf("The student was changed since version %d was read; it is at version %d", item.Version, current.Version), conflict: true})
+			continue
+		}
+		if !s.checkEditLock(id, owner) {
+			errs = append(errs, batchError{Index: i, Error: "Updating this student requires holding its edit lock", conflict: true})
+			continue
+		}
+
+		updated := item.Student
+		updated.ID = id
+		updated.DeletedAt = nil
+		updated.Version = current.Version + 1
+		before = append(before, current)
+		after = append(after, updated)
+		results[i].ID = publicID(id)
+	}
+
+	if s.cfg.DuplicateEmailPolicy != EmailPolicyAllow {
+		seen := map[string]int{}
+		for _, student := range after {
+			i := inBatch[student.ID]
+			key := strings.ToLower(student.Email)
+			if first, dup := seen[key]; dup {
+				errs = append(errs, batchError{Index: i, Error: fmt.Sprintf("Has the same email as item %d", first), conflict: true})
+				continue
+			}
+			seen[key] = i
+
+			// A student in the batch may be giving up the email; its own
+			// new email is checked when the loop reaches it
+			existing, err := s.store.FindByEmail(ctx, student.Email)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				respondStoreError(c, err)
+				return
+			}
+			if _, moving := inBatch[existing.ID]; err == nil && existing.ID != student.ID && !moving {
+				errs = append(errs, batchError{Index: i, Error: "A student with this email already exists", conflict: true})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Index < errs[j].Index })
+		respondBatchErrors(c, errs, "Some updates have problems; none were applied")
+		return
+	}
+
+	if err := s.updateAll(ctx, before, after); err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	s.invalidateSuggestIndex()
+	c.JSON(http.StatusOK, gin.H{"updated": len(after), "results": results})
+}
+
+// bulkDelete is the request body of DELETE /students: either the IDs to
+// delete or a filter matching them
+type bulkDelete struct {
+	IDs    []json.RawMessage `json:"ids"`
+	Filter *struct {
+		MinAge int    `json:"min_age"`
+		MaxAge int    `json:"max_age"`
+		Name   string `json:"name"`
+	} `json:"filter"`
+}
+
+// parseBodyID converts an ID from a request body, a number or an encoded
+// string as responses give it, to the internal ID
+func parseBodyID(raw json.RawMessage) (int, error) {
+	var encoded string
+	if json.Unmarshal(raw, &encoded) == nil {
+		return parseID(encoded)
+	}
+	return parseID(string(raw))
+}
+
+// deleteStudents handles DELETE /students, soft-deleting every student
+// listed by ID or matched by a filter as one change. IDs that match no live
+// student are reported rather than failing the request.
+func (s *server) deleteStudents(c *gin.Context) {
+	var body bulkDelete
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (len(body.IDs) > 0) == (body.Filter != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Send either ids or a filter"})
+		return
+	}
+	if len(body.IDs) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Send at most %d ids", maxBatchSize)})
+		return
+	}
+
+	var filter listFilter
+	ids := make([]int, len(body.IDs))
+	if body.Filter != nil {
+		filter = listFilter{minAge: body.Filter.MinAge, maxAge: body.Filter.MaxAge, name: strings.ToLower(strings.TrimSpace(body.Filter.Name))}
+		// An empty filter would delete everyone, which is never what a
+		// client sending one meant
+		if filter == (listFilter{}) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The filter needs min_age, max_age or name"})
+			return
+		}
+		if filter.minAge < 0 || filter.maxAge < 0 || (filter.maxAge > 0 && filter.minAge > filter.maxAge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid age range"})
+			return
+		}
+	} else {
+		for i, raw := range body.IDs {
+			id, err := parseBodyID(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid ID at index %d", i)})
+				return
+			}
+			ids[i] = id
+		}
+	}
+
+	ctx := c.Request.Context()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.store.List(ctx)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	live := liveStudents(list)
+
+	var targets []Student
+	notFound := []any{}
+	if body.Filter != nil {
+		targets = filter.apply(live)
+	} else {
+		byID := make(map[int]Student, len(live))
+		for _, student := range live {
+			byID[student.ID] = student
+		}
+		seen := map[int]bool{}
+		for i, id := range ids {
+			student, ok := byID[id]
+			switch {
+			case seen[id]:
+			case !ok:
+				notFound = append(notFound, body.IDs[i])
+			default:
+				targets = append(targets, student)
+			}
+			seen[id] = true
+		}
+	}
+
+	// Millisecond precision is the finest every backend keeps
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	deleted := make([]Student, len(targets))
+	deletedIDs := make([]any, len(targets))
+	for i, student := range targets {
+		student.DeletedAt = &now
+		student.Version++
+		deleted[i] = student
+		deletedIDs[i] = publicID(student.ID)
+	}
+	if err := s.updateAll(ctx, targets, deleted); err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	for _, student := range deleted {
+		s.releaseEditLock(student.ID)
+	}
+	if len(deleted) > 0 {
+		s.invalidateSuggestIndex()
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": len(deleted), "ids": deletedIDs, "not_found": notFound})
+}
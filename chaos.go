@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chaosHeader tells clients which fault, if any, was injected into a response
+const chaosHeader = "X-Chaos-Injected"
+
+// ChaosRule describes the faults injected into requests for one route
+type ChaosRule struct {
+	// Route is a method and Gin route pattern such as "GET /students/:id",
+	// or "*" for every route
+	Route       string  `json:"route"`
+	Latency     string  `json:"latency,omitempty"`
+	LatencyRate float64 `json:"latency_rate,omitempty"`
+	ErrorRate   float64 `json:"error_rate,omitempty"`
+	ErrorStatus int     `json:"error_status,omitempty"`
+	DropRate    float64 `json:"drop_rate,omitempty"`
+
+	latency time.Duration
+}
+
+// parseChaosRules parses and validates the CHAOS_RULES JSON array
+func parseChaosRules(raw string) ([]ChaosRule, error) {
+	var rules []ChaosRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("CHAOS_RULES must be a JSON array of rules: %v", err)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Route == "" {
+			return nil, fmt.Errorf("CHAOS_RULES[%d]: route is required", i)
+		}
+		for _, rate := range []float64{rule.LatencyRate, rule.ErrorRate, rule.DropRate} {
+			if rate < 0 || rate > 1 {
+				return nil, fmt.Errorf("CHAOS_RULES[%d]: rates must be between 0 and 1", i)
+			}
+		}
+		if rule.Latency != "" {
+			d, err := time.ParseDuration(rule.Latency)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("CHAOS_RULES[%d]: invalid latency %q", i, rule.Latency)
+			}
+			rule.latency = d
+		}
+		if rule.ErrorStatus == 0 {
+			rule.ErrorStatus = http.StatusServiceUnavailable
+		}
+		if rule.ErrorStatus < 400 || rule.ErrorStatus > 599 {
+			return nil, fmt.Errorf("CHAOS_RULES[%d]: error_status must be a 4xx or 5xx code", i)
+		}
+	}
+
+	return rules, nil
+}
+
+// chaosMiddleware injects latency, errors and dropped connections according
+// to the first rule matching each request's route
+func chaosMiddleware(rules []ChaosRule) gin.HandlerFunc {
+	log.Printf("chaos: fault injection enabled for %d rule(s); do not use in production", len(rules))
+
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		var rule *ChaosRule
+		for i := range rules {
+			if rules[i].Route == "*" || rules[i].Route == route {
+				rule = &rules[i]
+				break
+			}
+		}
+		if rule == nil {
+			c.Next()
+			return
+		}
+
+		if rand.Float64() < rule.DropRate {
+			// Close the connection without writing a response
+			if conn, _, err := c.Writer.Hijack(); err == nil {
+				conn.Close()
+				c.Abort()
+				return
+			}
+		}
+
+		if rule.latency > 0 && rand.Float64() < rule.LatencyRate {
+			c.Header(chaosHeader, "latency")
+			select {
+			case <-time.After(rule.latency):
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+		}
+
+		if rand.Float64() < rule.ErrorRate {
+			c.Header(chaosHeader, "error")
+			c.AbortWithStatusJSON(rule.ErrorStatus, gin.H{"error": "Injected fault"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/gin-gonic/gin"
+)
+
+// EmailPolicy controls how a new record with an already-used email is handled
+type EmailPolicy string
+
+const (
+	// EmailPolicyAllow stores duplicates as separate students
+	EmailPolicyAllow EmailPolicy = "allow"
+	// EmailPolicyReject refuses the request with 409 Conflict
+	EmailPolicyReject EmailPolicy = "reject"
+	// EmailPolicyMerge folds the incoming data into the existing student
+	EmailPolicyMerge EmailPolicy = "merge"
+)
+
+// Config holds the settings read from the environment at startup
+type Config struct {
+	Addr                 string
+	Region               string
+	AccessLog            string
+	StoreBackend         string
+	ShadowBackend        string
+	FilePath             string
+	OplogPath            string
+	SnapshotPath         string
+	IDSequencePath       string
+	SnapshotInterval     time.Duration
+	RetentionPeriod      time.Duration
+	RetentionInterval    time.Duration
+	RetentionWindow      *clockWindow
+	Timezone             *time.Location
+	OplogCompactAfter    int
+	BoltPath             string
+	SQLitePath           string
+	DatabaseURL          string
+	MySQLDSN             string
+	DBMaxOpenConns       int
+	DBMaxIdleConns       int
+	DBConnMaxLifetime    time.Duration
+	MongoURI             string
+	MongoDatabase        string
+	DynamoTable          string
+	DynamoRegion         string
+	DynamoEndpoint       string
+	EtcdEndpoints        string
+	EtcdPrefix           string
+	EtcdUsername         string
+	EtcdPassword         string
+	RedisURL             string
+	CacheTTL             time.Duration
+	MemoryMaxStudents    int
+	MaxExportBytes       int
+	ExportRecipients     []*age.X25519Recipient
+	OllamaURL            string
+	OllamaModel          string
+	HealthCheckInterval  time.Duration
+	LLMLog               bool
+	LLMLogSampleRate     float64
+	LLMLogRedact         bool
+	LLMLogMaxEntries     int
+	StartupAttempts      int
+	StartupRetryDelay    time.Duration
+	StartupRequireOllama bool
+	DuplicateEmailPolicy EmailPolicy
+	ValidationLevel      ValidationLevel
+	EditLockTTL          time.Duration
+	RequireEditLock      bool
+	ChaosRules           []ChaosRule
+	DeprecatedRoutes     []DeprecationRule
+	SLORules             []SLORule
+	SLOWindow            time.Duration
+	SLOAlertBurnRate     float64
+	ReplayLog            string
+	ReadOnly             bool
+	IDCodec              string
+	IDCodecSecret        string
+
+	AdminToken string
+
+	RequestSigningSecret  string
+	RequestSigningMaxSkew time.Duration
+
+	AbuseDetection        bool
+	AbuseAction           string
+	AbuseWindow           time.Duration
+	AbuseMaxNotFound      int
+	AbuseMaxSequential    int
+	AbuseFlagDuration     time.Duration
+	AbuseThrottleInterval time.Duration
+}
+
+// loadConfig reads the configuration from environment variables
+func loadConfig() (Config, error) {
+	var err error
+	cfg := Config{
+		Addr:                 getEnv("ADDR", ":8080"),
+		Region:               getEnv("REGION", ""),
+		AccessLog:            strings.ToLower(getEnv("ACCESS_LOG", accessLogAll)),
+		StoreBackend:         strings.ToLower(getEnv("STORE_BACKEND", "memory")),
+		ShadowBackend:        strings.ToLower(getEnv("SHADOW_BACKEND", "")),
+		FilePath:             getEnv("STUDENTS_FILE", "students.json"),
+		OplogPath:            getEnv("OPLOG_PATH", "students.oplog"),
+		SnapshotPath:         getEnv("SNAPSHOT_PATH", "students.snapshot.json"),
+		IDSequencePath:       getEnv("ID_SEQUENCE_PATH", ""),
+		BoltPath:             getEnv("BOLT_PATH", "students.bolt"),
+		SQLitePath:           getEnv("SQLITE_PATH", "students.db"),
+		DatabaseURL:          getEnv("DATABASE_URL", ""),
+		MySQLDSN:             getEnv("MYSQL_DSN", ""),
+		MongoURI:             getEnv("MONGODB_URI", ""),
+		MongoDatabase:        getEnv("MONGODB_DATABASE", "students"),
+		DynamoTable:          getEnv("DYNAMODB_TABLE", "students"),
+		DynamoRegion:         getEnv("DYNAMODB_REGION", ""),
+		DynamoEndpoint:       getEnv("DYNAMODB_ENDPOINT", ""),
+		EtcdEndpoints:        getEnv("ETCD_ENDPOINTS", ""),
+		EtcdPrefix:           getEnv("ETCD_PREFIX", "/students-api/"),
+		EtcdUsername:         getEnv("ETCD_USERNAME", ""),
+		EtcdPassword:         getEnv("ETCD_PASSWORD", ""),
+		RedisURL:             getEnv("REDIS_URL", ""),
+		OllamaURL:            strings.TrimRight(getEnv("OLLAMA_URL", "http://localhost:11434"), "/"),
+		OllamaModel:          getEnv("OLLAMA_MODEL", "llama2"),
+		DuplicateEmailPolicy: EmailPolicy(strings.ToLower(getEnv("DUPLICATE_EMAIL_POLICY", string(EmailPolicyAllow)))),
+		ValidationLevel:      ValidationLevel(strings.ToLower(getEnv("VALIDATION_LEVEL", string(ValidationWarn)))),
+		ReplayLog:            getEnv("REPLAY_LOG", ""),
+		IDCodec:              strings.ToLower(getEnv("ID_CODEC", "plain")),
+		IDCodecSecret:        getEnv("ID_CODEC_SECRET", ""),
+		AdminToken:           getEnv("ADMIN_TOKEN", ""),
+		RequestSigningSecret: getEnv("REQUEST_SIGNING_SECRET", ""),
+		AbuseAction:          strings.ToLower(getEnv("ABUSE_ACTION", abuseActionBlock)),
+	}
+	if cfg.EditLockTTL, err = getEnvDuration("EDIT_LOCK_TTL", 5*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.RequireEditLock, err = getEnvBool("REQUIRE_EDIT_LOCK", false); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.ReadOnly, err = getEnvBool("READ_ONLY", false); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.RequestSigningMaxSkew, err = getEnvDuration("REQUEST_SIGNING_MAX_SKEW", 5*time.Minute); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.AbuseDetection, err = getEnvBool("ABUSE_DETECTION", false); err != nil {
+		return Config{}, err
+	}
+	if cfg.AbuseWindow, err = getEnvDuration("ABUSE_WINDOW", time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.AbuseMaxNotFound, err = getEnvInt("ABUSE_MAX_NOT_FOUND", 30); err != nil {
+		return Config{}, err
+	}
+	if cfg.AbuseMaxSequential, err = getEnvInt("ABUSE_MAX_SEQUENTIAL", 20); err != nil {
+		return Config{}, err
+	}
+	if cfg.AbuseFlagDuration, err = getEnvDuration("ABUSE_FLAG_DURATION", 15*time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.AbuseThrottleInterval, err = getEnvDuration("ABUSE_THROTTLE_INTERVAL", time.Second); err != nil {
+		return Config{}, err
+	}
+	if cfg.AbuseAction != abuseActionBlock && cfg.AbuseAction != abuseActionThrottle {
+		return Config{}, fmt.Errorf("ABUSE_ACTION must be block or throttle (got %q)", cfg.AbuseAction)
+	}
+
+	if raw := getEnv("CHAOS_RULES", ""); raw != "" {
+		// Fault injection is a testing aid and must never reach production
+		if gin.Mode() == gin.ReleaseMode {
+			return Config{}, fmt.Errorf("CHAOS_RULES cannot be used when GIN_MODE is release")
+		}
+		if cfg.ChaosRules, err = parseChaosRules(raw); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if raw := getEnv("DEPRECATED_ROUTES", ""); raw != "" {
+		if cfg.DeprecatedRoutes, err = parseDeprecationRules(raw); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if raw := getEnv("SLO_RULES", ""); raw != "" {
+		if cfg.SLORules, err = parseSLORules(raw); err != nil {
+			return Config{}, err
+		}
+	}
+	if cfg.SLOWindow, err = getEnvDuration("SLO_WINDOW", time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.SLOWindow < time.Minute {
+		return Config{}, fmt.Errorf("SLO_WINDOW must be at least 1m (got %s)", cfg.SLOWindow)
+	}
+	if cfg.SLOAlertBurnRate, err = getEnvPositiveFloat("SLO_ALERT_BURN_RATE", 2); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.StartupAttempts, err = getEnvInt("STARTUP_ATTEMPTS", 5); err != nil {
+		return Config{}, err
+	}
+	if cfg.StartupRetryDelay, err = getEnvDuration("STARTUP_RETRY_DELAY", time.Second); err != nil {
+		return Config{}, err
+	}
+	if cfg.StartupRequireOllama, err = getEnvBool("STARTUP_REQUIRE_OLLAMA", false); err != nil {
+		return Config{}, err
+	}
+	if cfg.LLMLog, err = getEnvBool("LLM_LOG", false); err != nil {
+		return Config{}, err
+	}
+	if cfg.LLMLogSampleRate, err = getEnvFraction("LLM_LOG_SAMPLE_RATE", 1); err != nil {
+		return Config{}, err
+	}
+	if cfg.LLMLogRedact, err = getEnvBool("LLM_LOG_REDACT", true); err != nil {
+		return Config{}, err
+	}
+	if cfg.LLMLogMaxEntries, err = getEnvInt("LLM_LOG_MAX_ENTRIES", 500); err != nil {
+		return Config{}, err
+	}
+	if cfg.HealthCheckInterval, err = getEnvDuration("HEALTH_CHECK_INTERVAL", 10*time.Second); err != nil {
+		return Config{}, err
+	}
+	if cfg.MemoryMaxStudents, err = getEnvInt("MEMORY_MAX_STUDENTS", 100000); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxExportBytes, err = getEnvInt("MAX_EXPORT_BYTES", 32<<20); err != nil {
+		return Config{}, err
+	}
+	if cfg.ExportRecipients, err = parseAgeRecipients(getEnv("EXPORT_AGE_RECIPIENTS", "")); err != nil {
+		return Config{}, err
+	}
+	if cfg.OplogCompactAfter, err = getEnvInt("OPLOG_COMPACT_AFTER", 1000); err != nil {
+		return Config{}, err
+	}
+	// Unset keeps soft-deleted students forever
+	if cfg.RetentionPeriod, err = getEnvDuration("RETENTION_PERIOD", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.RetentionInterval, err = getEnvDuration("RETENTION_INTERVAL", time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.Timezone, err = time.LoadLocation(getEnv("TIMEZONE", "UTC")); err != nil {
+		return Config{}, fmt.Errorf("TIMEZONE must be an IANA zone such as Europe/Berlin: %v", err)
+	}
+	// Unset lets the purge run at any time of day
+	if raw := getEnv("RETENTION_WINDOW", ""); raw != "" {
+		window, err := parseClockWindow(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("RETENTION_WINDOW: %v", err)
+		}
+		cfg.RetentionWindow = &window
+	}
+	// Unset leaves the memory backend without snapshots
+	if cfg.SnapshotInterval, err = getEnvDuration("SNAPSHOT_INTERVAL", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.CacheTTL, err = getEnvDuration("CACHE_TTL", 30*time.Second); err != nil {
+		return Config{}, err
+	}
+	if cfg.DBMaxOpenConns, err = getEnvInt("DB_MAX_OPEN_CONNS", 10); err != nil {
+		return Config{}, err
+	}
+	if cfg.DBMaxIdleConns, err = getEnvInt("DB_MAX_IDLE_CONNS", 5); err != nil {
+		return Config{}, err
+	}
+	if cfg.DBConnMaxLifetime, err = getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.checkBackend("STORE_BACKEND", cfg.StoreBackend); err != nil {
+		return Config{}, err
+	}
+	if cfg.ShadowBackend != "" {
+		if err := cfg.checkBackend("SHADOW_BACKEND", cfg.ShadowBackend); err != nil {
+			return Config{}, err
+		}
+		// Two stores of one kind would share their settings, i.e. the same data
+		if cfg.ShadowBackend == cfg.StoreBackend {
+			return Config{}, fmt.Errorf("SHADOW_BACKEND must differ from STORE_BACKEND (both %q)", cfg.StoreBackend)
+		}
+	}
+
+	switch cfg.DuplicateEmailPolicy {
+	case EmailPolicyAllow, EmailPolicyReject, EmailPolicyMerge:
+	default:
+		return Config{}, fmt.Errorf("DUPLICATE_EMAIL_POLICY must be one of allow, reject, merge (got %q)", cfg.DuplicateEmailPolicy)
+	}
+
+	if !validAccessLog(cfg.AccessLog) {
+		return Config{}, fmt.Errorf("ACCESS_LOG must be one of all, errors, off (got %q)", cfg.AccessLog)
+	}
+
+	switch cfg.ValidationLevel {
+	case ValidationOff, ValidationWarn, ValidationStrict:
+	default:
+		return Config{}, fmt.Errorf("VALIDATION_LEVEL must be one of off, warn, strict (got %q)", cfg.ValidationLevel)
+	}
+
+	switch cfg.IDCodec {
+	case "plain":
+	case "keyed":
+		if len(cfg.IDCodecSecret) < 16 {
+			return Config{}, fmt.Errorf("ID_CODEC_SECRET must be at least 16 characters when ID_CODEC is keyed")
+		}
+	default:
+		return Config{}, fmt.Errorf("ID_CODEC must be plain or keyed (got %q)", cfg.IDCodec)
+	}
+
+	return cfg, nil
+}
+
+// checkBackend validates a store backend named by the setting key and
+// checks that the settings it needs are present
+func (c Config) checkBackend(key, backend string) error {
+	switch backend {
+	case "memory", "file", "oplog", "bolt", "sqlite", "dynamodb":
+	case "postgres":
+		if c.DatabaseURL == "" {
+			return fmt.Errorf("DATABASE_URL is required when %s is postgres", key)
+		}
+	case "mysql":
+		if c.MySQLDSN == "" {
+			return fmt.Errorf("MYSQL_DSN is required when %s is mysql", key)
+		}
+	case "mongodb":
+		if c.MongoURI == "" {
+			return fmt.Errorf("MONGODB_URI is required when %s is mongodb", key)
+		}
+	case "etcd":
+		if strings.TrimSpace(strings.ReplaceAll(c.EtcdEndpoints, ",", "")) == "" {
+			return fmt.Errorf("ETCD_ENDPOINTS is required when %s is etcd", key)
+		}
+	default:
+		return fmt.Errorf("%s must be memory, file, oplog, bolt, sqlite, postgres, mysql, mongodb, dynamodb or etcd (got %q)", key, backend)
+	}
+	return nil
+}
+
+// settings lists the effective configuration by environment variable name
+func (c Config) settings() map[string]string {
+	chaosRules := ""
+	if len(c.ChaosRules) > 0 {
+		raw, _ := json.Marshal(c.ChaosRules)
+		chaosRules = string(raw)
+	}
+
+	sloRules := ""
+	if len(c.SLORules) > 0 {
+		raw, _ := json.Marshal(c.SLORules)
+		sloRules = string(raw)
+	}
+	deprecatedRoutes := ""
+	if len(c.DeprecatedRoutes) > 0 {
+		raw, _ := json.Marshal(c.DeprecatedRoutes)
+		deprecatedRoutes = string(raw)
+	}
+	exportRecipients := make([]string, len(c.ExportRecipients))
+	for i, recipient := range c.ExportRecipients {
+		exportRecipients[i] = recipient.String()
+	}
+	retentionWindow := ""
+	if c.RetentionWindow != nil {
+		retentionWindow = c.RetentionWindow.String()
+	}
+
+	return map[string]string{
+		"ADDR":                     c.Addr,
+		"REGION":                   c.Region,
+		"ACCESS_LOG":               c.AccessLog,
+		"STORE_BACKEND":            c.StoreBackend,
+		"SHADOW_BACKEND":           c.ShadowBackend,
+		"STUDENTS_FILE":            c.FilePath,
+		"OPLOG_PATH":               c.OplogPath,
+		"OPLOG_COMPACT_AFTER":      strconv.Itoa(c.OplogCompactAfter),
+		"SNAPSHOT_PATH":            c.SnapshotPath,
+		"SNAPSHOT_INTERVAL":        c.SnapshotInterval.String(),
+		"ID_SEQUENCE_PATH":         c.IDSequencePath,
+		"RETENTION_PERIOD":         c.RetentionPeriod.String(),
+		"RETENTION_INTERVAL":       c.RetentionInterval.String(),
+		"RETENTION_WINDOW":         retentionWindow,
+		"TIMEZONE":                 c.Timezone.String(),
+		"BOLT_PATH":                c.BoltPath,
+		"SQLITE_PATH":              c.SQLitePath,
+		"DATABASE_URL":             redactURL(c.DatabaseURL),
+		"MYSQL_DSN":                redactMySQLDSN(c.MySQLDSN),
+		"DB_MAX_OPEN_CONNS":        strconv.Itoa(c.DBMaxOpenConns),
+		"DB_MAX_IDLE_CONNS":        strconv.Itoa(c.DBMaxIdleConns),
+		"DB_CONN_MAX_LIFETIME":     c.DBConnMaxLifetime.String(),
+		"MONGODB_URI":              redactURL(c.MongoURI),
+		"MONGODB_DATABASE":         c.MongoDatabase,
+		"DYNAMODB_TABLE":           c.DynamoTable,
+		"DYNAMODB_REGION":          c.DynamoRegion,
+		"DYNAMODB_ENDPOINT":        c.DynamoEndpoint,
+		"ETCD_ENDPOINTS":           c.EtcdEndpoints,
+		"ETCD_PREFIX":              c.EtcdPrefix,
+		"ETCD_USERNAME":            c.EtcdUsername,
+		"ETCD_PASSWORD":            redact(c.EtcdPassword),
+		"REDIS_URL":                redactURL(c.RedisURL),
+		"CACHE_TTL":                c.CacheTTL.String(),
+		"MEMORY_MAX_STUDENTS":      strconv.Itoa(c.MemoryMaxStudents),
+		"MAX_EXPORT_BYTES":         strconv.Itoa(c.MaxExportBytes),
+		"EXPORT_AGE_RECIPIENTS":    strings.Join(exportRecipients, ","),
+		"OLLAMA_URL":               c.OllamaURL,
+		"OLLAMA_MODEL":             c.OllamaModel,
+		"STARTUP_ATTEMPTS":         strconv.Itoa(c.StartupAttempts),
+		"STARTUP_RETRY_DELAY":      c.StartupRetryDelay.String(),
+		"STARTUP_REQUIRE_OLLAMA":   strconv.FormatBool(c.StartupRequireOllama),
+		"LLM_LOG":                  strconv.FormatBool(c.LLMLog),
+		"LLM_LOG_SAMPLE_RATE":      strconv.FormatFloat(c.LLMLogSampleRate, 'g', -1, 64),
+		"LLM_LOG_REDACT":           strconv.FormatBool(c.LLMLogRedact),
+		"LLM_LOG_MAX_ENTRIES":      strconv.Itoa(c.LLMLogMaxEntries),
+		"HEALTH_CHECK_INTERVAL":    c.HealthCheckInterval.String(),
+		"DUPLICATE_EMAIL_POLICY":   string(c.DuplicateEmailPolicy),
+		"VALIDATION_LEVEL":         string(c.ValidationLevel),
+		"EDIT_LOCK_TTL":            c.EditLockTTL.String(),
+		"REQUIRE_EDIT_LOCK":        strconv.FormatBool(c.RequireEditLock),
+		"CHAOS_RULES":              chaosRules,
+		"DEPRECATED_ROUTES":        deprecatedRoutes,
+		"SLO_RULES":                sloRules,
+		"SLO_WINDOW":               c.SLOWindow.String(),
+		"SLO_ALERT_BURN_RATE":      strconv.FormatFloat(c.SLOAlertBurnRate, 'g', -1, 64),
+		"REPLAY_LOG":               c.ReplayLog,
+		"READ_ONLY":                strconv.FormatBool(c.ReadOnly),
+		"ID_CODEC":                 c.IDCodec,
+		"ID_CODEC_SECRET":          redact(c.IDCodecSecret),
+		"ADMIN_TOKEN":              redact(c.AdminToken),
+		"REQUEST_SIGNING_SECRET":   redact(c.RequestSigningSecret),
+		"REQUEST_SIGNING_MAX_SKEW": c.RequestSigningMaxSkew.String(),
+		"ABUSE_DETECTION":          strconv.FormatBool(c.AbuseDetection),
+		"ABUSE_ACTION":             c.AbuseAction,
+		"ABUSE_WINDOW":             c.AbuseWindow.String(),
+		"ABUSE_MAX_NOT_FOUND":      strconv.Itoa(c.AbuseMaxNotFound),
+		"ABUSE_MAX_SEQUENTIAL":     strconv.Itoa(c.AbuseMaxSequential),
+		"ABUSE_FLAG_DURATION":      c.AbuseFlagDuration.String(),
+		"ABUSE_THROTTLE_INTERVAL":  c.AbuseThrottleInterval.String(),
+	}
+}
+
+// redact hides a secret setting, only reporting whether it is set
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "(set)"
+}
+
+// redactURL hides the password in a connection URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redact(raw)
+	}
+	return u.Redacted()
+}
+
+// getEnv returns the value of the environment variable key, or def if it is unset
+func getEnv(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return def
+}
+
+// getEnvDuration parses the environment variable key as a time.Duration
+func getEnvDuration(key string, def time.Duration) (time.Duration, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 30s or 5m (got %q)", key, value)
+	}
+	return d, nil
+}
+
+// getEnvInt parses the environment variable key as a positive integer
+func getEnvInt(key string, def int) (int, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer (got %q)", key, value)
+	}
+	return n, nil
+}
+
+// getEnvFraction parses the environment variable key as a number from 0 to 1
+func getEnvFraction(key string, def float64) (float64, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 || f > 1 {
+		return 0, fmt.Errorf("%s must be a number from 0 to 1 (got %q)", key, value)
+	}
+	return f, nil
+}
+
+// getEnvPositiveFloat parses the environment variable key as a positive number
+func getEnvPositiveFloat(key string, def float64) (float64, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("%s must be a positive number (got %q)", key, value)
+	}
+	return f, nil
+}
+
+// getEnvBool parses the environment variable key as a boolean
+func getEnvBool(key string, def bool) (bool, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false (got %q)", key, value)
+	}
+	return b, nil
+}
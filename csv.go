@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// csvHeader is the first row of a CSV roster
+var csvHeader = []string{"id", "name", "age", "email", "version"}
+
+// utf8BOM makes Excel read a CSV file as UTF-8 rather than the local code
+// page, so accented names survive
+const utf8BOM = "\ufeff"
+
+// formulaPrefixes are the first characters that make a spreadsheet treat
+// a cell as a formula: =, +, - and @, and the tab and carriage return that
+// can hide one
+const formulaPrefixes = "=+-@\t\r"
+
+// csvCell keeps a value from being run as a formula by a spreadsheet
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// csvValue undoes csvCell for a value read back from a CSV file, then
+// trims it
+func csvValue(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(cell[1])) {
+		cell = cell[1:]
+	}
+	return strings.TrimSpace(cell)
+}
+
+// exportStudentsCSV handles GET /students/export, downloading the live
+// students as a CSV roster. It takes the filters and sort of GET /students;
+// ?format= must be csv, the default.
+func (s *server) exportStudentsCSV(c *gin.Context) {
+	if format := c.DefaultQuery("format", "csv"); format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv"})
+		return
+	}
+	filter, ok := parseFilter(c)
+	if !ok {
+		return
+	}
+	order, ok := parseSort(c)
+	if !ok {
+		return
+	}
+
+	list, err := s.store.List(c.Request.Context())
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	list = filter.apply(liveStudents(list))
+	order.apply(list)
+
+	out, err := s.startExport(c, "students-"+exportTimestamp()+".csv", "text/csv; charset=utf-8")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if _, err := io.WriteString(out, utf8BOM); err != nil {
+		return
+	}
+	w := csv.NewWriter(out)
+	// Excel expects CRLF line endings
+	w.UseCRLF = true
+	w.Write(csvHeader)
+	for _, student := range list {
+		w.Write([]string{
+			fmt.Sprint(publicID(student.ID)),
+			csvCell(student.Name),
+			strconv.Itoa(student.Age),
+			csvCell(student.Email),
+			strconv.Itoa(student.Version),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := out.Close(); err != nil {
+		c.Error(err)
+	}
+}
+
+// maxImportRows caps the number of students in one CSV import
+const maxImportRows = 10000
+
+// importError is the problem with one row of a CSV import. Row is the
+// line number in the file, counting the header as line 1.
+type importError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// importResult is the outcome for one imported row
+type importResult struct {
+	Row      int      `json:"row"`
+	ID       any      `json:"id"`
+	Status   string   `json:"status"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// importRow is a row that passed validation, waiting to be stored
+type importRow struct {
+	line     int
+	student  Student
+	warnings []string
+}
+
+// readImportRows reads a CSV roster with a header naming at least the
+// name, age and email columns, in any order; other columns, such as the
+// id and version of an export, are ignored. It returns the valid rows and
+// the problems with the others, or an error if the file itself is unusable.
+func (s *server) readImportRows(file io.Reader) ([]importRow, []importError, error) {
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read the header row: %v", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, utf8BOM)))
+		columns[name] = i
+	}
+	for _, name := range []string{"name", "age", "email"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("the header row has no %s column", name)
+		}
+	}
+
+	var rows []importRow
+	var errs []importError
+	for count := 0; ; count++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			// A malformed row can swallow the rest of the file, so stop here
+			errs = append(errs, importError{Row: parseErr.StartLine, Error: parseErr.Err.Error()})
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if count == maxImportRows {
+			return nil, nil, fmt.Errorf("import at most %d rows", maxImportRows)
+		}
+		line, _ := r.FieldPos(0)
+		if len(record) != len(header) {
+			errs = append(errs, importError{Row: line, Error: fmt.Sprintf("Has %d fields; the header has %d", len(record), len(header))})
+			continue
+		}
+
+		// An age that is not a number stays 0, which validation refuses
+		age, _ := strconv.Atoi(csvValue(record[columns["age"]]))
+		student := Student{
+			Name:    csvValue(record[columns["name"]]),
+			Age:     age,
+			Email:   csvValue(record[columns["email"]]),
+			Version: 1,
+		}
+		warnings, problem := s.validateStudent(student)
+		if problem != "" {
+			errs = append(errs, importError{Row: line, Error: problem})
+			continue
+		}
+		rows = append(rows, importRow{line: line, student: student, warnings: warnings})
+	}
+	return rows, errs, nil
+}
+
+// importStudents handles POST /students/import, a multipart upload of a
+// CSV roster in the file field. Unlike POST /students/batch, every valid
+// row is stored even if others are not; the response reports the outcome
+// of each row.
+func (s *server) importStudents(c *gin.Context) {
+	upload, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the CSV file as the multipart field file"})
+		return
+	}
+	file, err := upload.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	rows, errs, err := s.readImportRows(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV file: " + err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := []importResult{}
+	created, merged := 0, 0
+	seen := map[string]int{}
+	for _, row := range rows {
+		student := row.student
+		key := strings.ToLower(student.Email)
+		if first, dup := seen[key]; dup && s.cfg.DuplicateEmailPolicy != EmailPolicyAllow {
+			errs = append(errs, importError{Row: row.line, Error: fmt.Sprintf("Has the same email as row %d", first)})
+			continue
+		}
+		seen[key] = row.line
+
+		status := "created"
+		if s.cfg.DuplicateEmailPolicy != EmailPolicyAllow {
+			existing, err := s.store.FindByEmail(ctx, student.Email)
+			switch {
+			case errors.Is(err, ErrNotFound):
+			case err != nil:
+				log.Printf("store: import row %d: %v", row.line, err)
+				errs = append(errs, importError{Row: row.line, Error: "Internal server error"})
+				continue
+			case s.cfg.DuplicateEmailPolicy == EmailPolicyReject:
+				errs = append(errs, importError{Row: row.line, Error: "A student with this email already exists"})
+				continue
+			case !s.checkEditLock(existing.ID, lockOwner(c)):
+				errs = append(errs, importError{Row: row.line, Error: "Merging into this student requires holding its edit lock"})
+				continue
+			default:
+				student.ID = existing.ID
+				student.Version = existing.Version + 1
+				status = "merged"
+			}
+		}
+
+		if status == "merged" {
+			err = s.store.Update(ctx, student)
+		} else {
+			student, err = s.store.Create(ctx, student)
+		}
+		switch {
+		case errors.Is(err, ErrStoreFull):
+			errs = append(errs, importError{Row: row.line, Error: "The student store is full"})
+			continue
+		case err != nil:
+			log.Printf("store: import row %d: %v", row.line, err)
+			errs = append(errs, importError{Row: row.line, Error: "Internal server error"})
+			continue
+		case status == "merged":
+			merged++
+		default:
+			created++
+		}
+		results = append(results, importResult{Row: row.line, ID: publicID(student.ID), Status: status, Warnings: row.warnings})
+	}
+	s.warnQuota(c)
+	if len(results) > 0 {
+		s.invalidateSuggestIndex()
+	}
+	if errs == nil {
+		errs = []importError{}
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+
+	status := http.StatusOK
+	switch {
+	case created > 0:
+		status = http.StatusCreated
+	case len(results) == 0 && len(errs) > 0:
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{"created": created, "merged": merged, "failed": len(errs), "results": results, "errors": errs})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deprecatedRequests counts requests to deprecated routes by route, to show
+// who still has to move before the sunset
+var deprecatedRequests = expvar.NewMap("deprecated_requests_total")
+
+// DeprecationRule marks one route as deprecated
+type DeprecationRule struct {
+	// Route is a method and Gin route pattern such as "GET /students/:id"
+	Route string `json:"route"`
+	// Deprecated is when the route was deprecated, as a date or RFC 3339 time
+	Deprecated string `json:"deprecated"`
+	// Sunset, optionally, is when the route will stop working
+	Sunset string `json:"sunset,omitempty"`
+	// Link, optionally, points to the migration guide or replacement
+	Link string `json:"link,omitempty"`
+	// Gone makes the route answer 410 Gone once its sunset has passed
+	Gone bool `json:"gone,omitempty"`
+
+	deprecated time.Time
+	sunset     time.Time
+}
+
+// parseDeprecationTime accepts a date such as 2026-12-31, meaning its
+// start in UTC, or an RFC 3339 time
+func parseDeprecationTime(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// parseDeprecationRules parses and validates the DEPRECATED_ROUTES JSON array
+func parseDeprecationRules(raw string) ([]DeprecationRule, error) {
+	var rules []DeprecationRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("DEPRECATED_ROUTES must be a JSON array of rules: %v", err)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Route == "" {
+			return nil, fmt.Errorf("DEPRECATED_ROUTES[%d]: route is required", i)
+		}
+		var err error
+		if rule.deprecated, err = parseDeprecationTime(rule.Deprecated); err != nil {
+			return nil, fmt.Errorf("DEPRECATED_ROUTES[%d]: deprecated must be a date such as 2026-12-31 (got %q)", i, rule.Deprecated)
+		}
+		if rule.Sunset != "" {
+			if rule.sunset, err = parseDeprecationTime(rule.Sunset); err != nil {
+				return nil, fmt.Errorf("DEPRECATED_ROUTES[%d]: sunset must be a date such as 2026-12-31 (got %q)", i, rule.Sunset)
+			}
+			if rule.sunset.Before(rule.deprecated) {
+				return nil, fmt.Errorf("DEPRECATED_ROUTES[%d]: sunset is before deprecated", i)
+			}
+		}
+		if rule.Gone && rule.Sunset == "" {
+			return nil, fmt.Errorf("DEPRECATED_ROUTES[%d]: gone needs a sunset", i)
+		}
+	}
+
+	return rules, nil
+}
+
+// deprecationMiddleware adds the Deprecation (RFC 9745) and Sunset
+// (RFC 8594) headers to responses from deprecated routes, with a Link to
+// the migration guide and a Warning for clients that only log those.
+// Routes marked gone answer 410 once their sunset has passed.
+func deprecationMiddleware(rules []DeprecationRule) gin.HandlerFunc {
+	byRoute := make(map[string]*DeprecationRule, len(rules))
+	for i := range rules {
+		byRoute[rules[i].Route] = &rules[i]
+	}
+
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		rule, ok := byRoute[route]
+		if !ok {
+			c.Next()
+			return
+		}
+		deprecatedRequests.Add(route, 1)
+
+		c.Header("Deprecation", "@"+strconv.FormatInt(rule.deprecated.Unix(), 10))
+		warning := "Deprecated API"
+		if !rule.sunset.IsZero() {
+			c.Header("Sunset", rule.sunset.UTC().Format(http.TimeFormat))
+			warning += ", removed after " + rule.sunset.UTC().Format(time.DateOnly)
+		}
+		if rule.Link != "" {
+			c.Header("Link", "<"+rule.Link+`>; rel="deprecation"`)
+			warning += ", see " + rule.Link
+		}
+		c.Header("Warning", `299 - "`+warning+`"`)
+
+		if rule.Gone && time.Now().After(rule.sunset) {
+			body := gin.H{"error": "This endpoint has been removed", "sunset": rule.Sunset}
+			if rule.Link != "" {
+				body["link"] = rule.Link
+			}
+			c.AbortWithStatusJSON(http.StatusGone, body)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"runtime/pprof"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryLimitSetting formats a soft memory limit the way GOMEMLIMIT spells it
+func memoryLimitSetting(limit int64) string {
+	if limit == math.MaxInt64 {
+		return "off"
+	}
+	return strconv.FormatInt(limit, 10)
+}
+
+// gcSettings reads the GOGC percentage and the soft memory limit. They are
+// read through runtime/metrics because the debug setters would change them,
+// if only for a moment, under concurrent requests.
+func gcSettings() (gcPercent, memoryLimit int64) {
+	samples := []metrics.Sample{{Name: "/gc/gogc:percent"}, {Name: "/gc/gomemlimit:bytes"}}
+	metrics.Read(samples)
+	// Both are stored as int64 by the runtime; GOGC=off reads as -1
+	return int64(samples[0].Value.Uint64()), int64(samples[1].Value.Uint64())
+}
+
+// runtimeStats handles GET /admin/debug/runtime
+func runtimeStats(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+
+	gcPercent, memoryLimit := gcSettings()
+
+	var lastGC *time.Time
+	if !gc.LastGC.IsZero() {
+		t := gc.LastGC.UTC()
+		lastGC = &t
+	}
+	var lastPause time.Duration
+	if len(gc.Pause) > 0 {
+		lastPause = gc.Pause[0]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"go_version": runtime.Version(),
+		"goroutines": runtime.NumGoroutine(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"memory": gin.H{
+			"heap_alloc_bytes": mem.HeapAlloc,
+			"heap_inuse_bytes": mem.HeapInuse,
+			"heap_objects":     mem.HeapObjects,
+			"sys_bytes":        mem.Sys,
+			"next_gc_bytes":    mem.NextGC,
+			"gomemlimit":       memoryLimitSetting(memoryLimit),
+		},
+		"gc": gin.H{
+			"count":        gc.NumGC,
+			"last_run":     lastGC,
+			"last_pause":   lastPause.String(),
+			"pause_total":  gc.PauseTotal.String(),
+			"cpu_fraction": mem.GCCPUFraction,
+			"gogc":         gcPercent,
+		},
+	})
+}
+
+// setMemoryLimit handles PUT /admin/debug/memory-limit. It changes the soft
+// memory limit until the next restart; a limit of 0 removes it.
+func setMemoryLimit(c *gin.Context) {
+	var body struct {
+		LimitBytes *int64 `json:"limit_bytes"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.LimitBytes == nil || *body.LimitBytes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit_bytes must be a number of bytes, or 0 to remove the limit"})
+		return
+	}
+
+	limit := *body.LimitBytes
+	if limit == 0 {
+		limit = math.MaxInt64
+	}
+	previous := debug.SetMemoryLimit(limit)
+	c.JSON(http.StatusOK, gin.H{
+		"gomemlimit":          memoryLimitSetting(limit),
+		"previous_gomemlimit": memoryLimitSetting(previous),
+	})
+}
+
+// heapProfile handles GET /admin/debug/heap, downloading a pprof heap
+// profile for `go tool pprof`. With gc=true a collection runs first so the
+// profile reflects only live objects.
+func heapProfile(c *gin.Context) {
+	if gc, _ := strconv.ParseBool(c.Query("gc")); gc {
+		runtime.GC()
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="heap-`+time.Now().UTC().Format("20060102T150405Z")+`.pprof"`)
+	if err := pprof.Lookup("heap").WriteTo(c.Writer, 0); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// emailDomainMigration is the request body of POST /admin/migrate-email-domain
+type emailDomainMigration struct {
+	From   string `json:"from" binding:"required"`
+	To     string `json:"to" binding:"required"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// emailChange is one rewritten email in the migration report
+type emailChange struct {
+	ID   any    `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// validDomain reports whether domain looks like a mail domain
+func validDomain(domain string) bool {
+	return domain != "" && strings.Contains(domain, ".") && !strings.ContainsAny(domain, "@ \t\r\n")
+}
+
+// migrateEmailDomain handles POST /admin/migrate-email-domain, moving every
+// student whose email is at one domain to another, for when a school changes
+// its mail provider. Deleted students are moved too, so restoring one does
+// not bring back the old domain. With dry_run nothing is written and the
+// report shows what would change. Either every email is rewritten or, if
+// one would collide with another student, none are.
+func (s *server) migrateEmailDomain(c *gin.Context) {
+	var body emailDomainMigration
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from := strings.TrimPrefix(body.From, "@")
+	to := strings.TrimPrefix(body.To, "@")
+	if !validDomain(from) || !validDomain(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be mail domains such as example.edu"})
+		return
+	}
+	if strings.EqualFold(from, to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are the same domain"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.store.List(ctx)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	var before, after []Student
+	changes := []emailChange{}
+	for _, student := range list {
+		local, domain, ok := strings.Cut(student.Email, "@")
+		if !ok || !strings.EqualFold(domain, from) {
+			continue
+		}
+		moved := student
+		moved.Email = local + "@" + to
+		moved.Version++
+		before = append(before, student)
+		after = append(after, moved)
+		changes = append(changes, emailChange{ID: publicID(student.ID), From: student.Email, To: moved.Email})
+	}
+
+	if s.cfg.DuplicateEmailPolicy != EmailPolicyAllow {
+		conflicts, err := s.emailConflicts(ctx, before, after)
+		if err != nil {
+			respondStoreError(c, err)
+			return
+		}
+		if len(conflicts) > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":     "Some new emails are already taken; nothing was changed",
+				"conflicts": conflicts,
+			})
+			return
+		}
+	}
+
+	if !body.DryRun && len(after) > 0 {
+		if err := s.updateAll(ctx, before, after); err != nil {
+			respondStoreError(c, err)
+			return
+		}
+		s.invalidateSuggestIndex()
+		ids := make([]int, len(after))
+		for i, student := range after {
+			ids[i] = student.ID
+		}
+		log.Printf("admin: migrated the emails of %d student(s) from %s to %s: ids %v", len(after), from, to, ids)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run": body.DryRun,
+		"from":    from,
+		"to":      to,
+		"changed": len(changes),
+		"changes": changes,
+	})
+}
+
+// emailConflicts lists the live students in after whose new email is held
+// by another student, or by another student in after
+func (s *server) emailConflicts(ctx context.Context, before, after []Student) ([]emailChange, error) {
+	claimed := map[string]int{}
+	conflicts := []emailChange{}
+	for i, student := range after {
+		conflict := emailChange{ID: publicID(student.ID), From: before[i].Email, To: student.Email}
+		if student.DeletedAt != nil {
+			continue
+		}
+		key := strings.ToLower(student.Email)
+		if _, taken := claimed[key]; taken {
+			conflicts = append(conflicts, conflict)
+			continue
+		}
+		claimed[key] = student.ID
+
+		existing, err := s.store.FindByEmail(ctx, student.Email)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if existing.ID != student.ID {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/gin-gonic/gin"
+)
+
+// parseAgeRecipients parses the comma-separated age public keys of
+// EXPORT_AGE_RECIPIENTS
+func parseAgeRecipients(raw string) ([]*age.X25519Recipient, error) {
+	var recipients []*age.X25519Recipient
+	for _, key := range strings.Split(raw, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		recipient, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, fmt.Errorf("EXPORT_AGE_RECIPIENTS: %v", err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// nopWriteCloser adds a no-op Close to a writer
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// startExport begins an attachment download called name. With
+// EXPORT_AGE_RECIPIENTS set, the returned writer encrypts to them and the
+// file gets an .age suffix, so the plaintext never leaves the process. The
+// caller must Close the writer to finish the file.
+func (s *server) startExport(c *gin.Context, name, contentType string) (io.WriteCloser, error) {
+	if len(s.cfg.ExportRecipients) == 0 {
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+		c.Status(http.StatusOK)
+		return nopWriteCloser{c.Writer}, nil
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", `attachment; filename="`+name+`.age"`)
+	c.Status(http.StatusOK)
+	recipients := make([]age.Recipient, len(s.cfg.ExportRecipients))
+	for i, recipient := range s.cfg.ExportRecipients {
+		recipients[i] = recipient
+	}
+	return age.Encrypt(c.Writer, recipients...)
+}
+
+// exportTimestamp names export files by when they were taken
+func exportTimestamp() string {
+	return time.Now().UTC().Format("20060102T150405Z")
+}
+
+// exportStudents handles GET /admin/export, downloading every student,
+// deleted ones included, in the file backend's format. Pointing
+// STUDENTS_FILE at the (decrypted) download restores it. Students are
+// encoded one at a time as they are written out, so the response is never
+// held in memory whole.
+func (s *server) exportStudents(c *gin.Context) {
+	list, err := s.store.List(c.Request.Context())
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	nextID := 1
+	for _, student := range list {
+		nextID = max(nextID, student.ID+1)
+	}
+
+	w, err := s.startExport(c, "students-"+exportTimestamp()+".json", "application/json")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := writeSnapshotJSON(w, nextID, list); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		c.Error(err)
+	}
+}
+
+// writeSnapshotJSON writes a fileSnapshot of list to w, one student per line
+func writeSnapshotJSON(w io.Writer, nextID int, list []Student) error {
+	if _, err := fmt.Fprintf(w, "{\"next_id\": %d, \"students\": [", nextID); err != nil {
+		return err
+	}
+	for i, student := range list {
+		raw, err := json.Marshal(student)
+		if err != nil {
+			return err
+		}
+		sep := ",\n  "
+		if i == 0 {
+			sep = "\n  "
+		}
+		if _, err := io.WriteString(w, sep+string(raw)); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]}\n")
+	return err
+}
+
+// exportLLMLog handles GET /admin/llm-log/export
+func (s *server) exportLLMLog(c *gin.Context) {
+	w, err := s.startExport(c, "llm-log-"+exportTimestamp()+".jsonl", "application/x-ndjson")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := s.llmLog.writeExport(w); err != nil {
+		c.Error(err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		c.Error(err)
+	}
+}
+
+// runDecryptExport implements the decrypt-export subcommand, writing the
+// plaintext of an encrypted export to stdout. It returns the exit code.
+func runDecryptExport(args []string) int {
+	flags := flag.NewFlagSet("decrypt-export", flag.ContinueOnError)
+	identityPath := flags.String("identity", "", "age identity file holding the private key")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *identityPath == "" || flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: decrypt-export -identity key.txt export.age")
+		return 2
+	}
+
+	keyFile, err := os.Open(*identityPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer keyFile.Close()
+	identities, err := age.ParseIdentities(keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decrypt-export: read identity: %v\n", err)
+		return 1
+	}
+
+	in, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer in.Close()
+	plain, err := age.Decrypt(in, identities...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decrypt-export: %v\n", err)
+		return 1
+	}
+	if _, err := io.Copy(os.Stdout, plain); err != nil {
+		fmt.Fprintf(os.Stderr, "decrypt-export: %v\n", err)
+		return 1
+	}
+	return 0
+}
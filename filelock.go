@@ -0,0 +1,42 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// dataFileLockTimeout is how long opening a data file waits for another
+// process to let go of it, the same as the bolt backend's open timeout
+const dataFileLockTimeout = 5 * time.Second
+
+// lockDataFile takes an exclusive lock on path+".lock", so that only one
+// process at a time uses the data file at path. The data file itself is
+// replaced by renames, which would drop a lock held on it. The lock is
+// released when the returned file is closed, or when the process exits.
+func lockDataFile(path string) (*os.File, error) {
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	deadline := time.Now().Add(dataFileLockTimeout)
+	for {
+		err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return lock, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			lock.Close()
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		if time.Now().After(deadline) {
+			lock.Close()
+			return nil, fmt.Errorf("%s is in use by another process; stop the server first", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
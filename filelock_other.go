@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// lockDataFile only creates the lock file on platforms without flock, so
+// nothing stops two processes from using the data file at path
+func lockDataFile(path string) (*os.File, error) {
+	return os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+}
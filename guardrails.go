@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Guardrail metrics, served with the runtime memory stats on
+// /admin/debug/vars
+var (
+	memoryStoreStudents    = expvar.NewInt("memory_store_students")
+	memoryStoreRejected    = expvar.NewInt("memory_store_rejected_total")
+	exportRejectedTooLarge = expvar.NewInt("export_rejected_too_large_total")
+)
+
+// errExportTooLarge is returned when an encoded export would pass MAX_EXPORT_BYTES
+var errExportTooLarge = errors.New("export too large")
+
+// encodeStudentList encodes students as a JSON array one element at a time,
+// giving up as soon as the output would exceed limit bytes so the buffer
+// never grows past it
+func encodeStudentList(list []Student, limit int) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, student := range list {
+		raw, err := json.Marshal(present(student))
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len()+len(raw)+2 > limit {
+			return nil, errExportTooLarge
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(raw)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// respondExportTooLarge reports an export refused by MAX_EXPORT_BYTES
+func respondExportTooLarge(c *gin.Context, limit int) {
+	exportRejectedTooLarge.Add(1)
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error":       "The response would exceed the export size limit",
+		"limit_bytes": limit,
+		"hint":        "Raise MAX_EXPORT_BYTES or remove students that are no longer needed",
+	})
+}
+
+// quotaWarnLevels are the fractions of MEMORY_MAX_STUDENTS at which
+// creates start carrying X-Quota-Remaining and a warning is logged, so
+// operators can react before creates are refused
+var quotaWarnLevels = []float64{0.8, 0.95}
+
+// capacityReporter is a store with a fixed limit on how many students it holds
+type capacityReporter interface {
+	capacity() (used, limit int)
+}
+
+// storeCapacity finds the capacity limited store behind the cache and
+// shadow wrappers, if there is one
+func storeCapacity(store StudentStore) (capacityReporter, bool) {
+	for {
+		switch s := store.(type) {
+		case capacityReporter:
+			return s, true
+		case *cachedStore:
+			store = s.StudentStore
+		case *shadowStore:
+			store = s.StudentStore
+		default:
+			return nil, false
+		}
+	}
+}
+
+// warnQuota adds X-Quota-Remaining to the response once the store is past
+// the first warning level, and logs each level as it is crossed. The caller
+// must hold s.mu.
+func (s *server) warnQuota(c *gin.Context) {
+	store, ok := storeCapacity(s.store)
+	if !ok {
+		return
+	}
+	used, limit := store.capacity()
+	level := 0
+	for _, warnAt := range quotaWarnLevels {
+		if float64(used) >= warnAt*float64(limit) {
+			level++
+		}
+	}
+	if level > 0 {
+		c.Header("X-Quota-Remaining", strconv.Itoa(max(limit-used, 0)))
+	}
+	if level > s.quotaLevel {
+		log.Printf("quota: the student store holds %d of %d students (%.0f%% warning level)", used, limit, quotaWarnLevels[level-1]*100)
+	}
+	s.quotaLevel = level
+}
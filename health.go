@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dependency is one row of the degradation matrix: how to probe a backing
+// service and what the API does while it is down
+type dependency struct {
+	name string
+	// critical dependencies answer every API request with 503 while down;
+	// the others only degrade the features that need them
+	critical bool
+	// whenDown describes the degraded behavior, for /readyz
+	whenDown string
+	check    func(ctx context.Context) error
+}
+
+// dependencyStatus is the latest probe result for a dependency
+type dependencyStatus struct {
+	Status      string    `json:"status"`
+	Critical    bool      `json:"critical"`
+	WhenDown    string    `json:"when_down"`
+	Error       string    `json:"error,omitempty"`
+	LastChecked time.Time `json:"last_checked"`
+}
+
+// healthMonitor probes the dependencies in the background and keeps their
+// latest status for the degradation middleware, the summarizer and /readyz
+type healthMonitor struct {
+	deps     []dependency
+	interval time.Duration
+
+	mu     sync.RWMutex
+	status map[string]dependencyStatus
+}
+
+// newHealthMonitor builds the degradation matrix for a store and config
+func newHealthMonitor(store StudentStore, c Config) *healthMonitor {
+	h := &healthMonitor{
+		deps: []dependency{
+			{
+				name:     "store",
+				critical: true,
+				whenDown: "student endpoints respond 503",
+				check:    store.Ping,
+			},
+			{
+				name:     "ollama",
+				whenDown: "summaries use the built-in fallback summarizer",
+				check: func(context.Context) error {
+					if result := checkOllama(c); !result.OK {
+						return errors.New(result.Error)
+					}
+					return nil
+				},
+			},
+		},
+		interval: c.HealthCheckInterval,
+		status:   map[string]dependencyStatus{},
+	}
+	if cache, ok := store.(*cachedStore); ok {
+		h.deps = append(h.deps, dependency{
+			name:     "redis",
+			whenDown: "reads skip the cache and go to the store",
+			check:    cache.pingCache,
+		})
+	}
+	return h
+}
+
+// run probes every dependency now and then every interval until ctx is done
+func (h *healthMonitor) run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		h.probe(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe checks each dependency once, logging changes in status
+func (h *healthMonitor) probe(ctx context.Context) {
+	for _, dep := range h.deps {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := dep.check(checkCtx)
+		cancel()
+		h.record(dep.name, err)
+	}
+}
+
+// record stores the outcome of a probe or of a failed live call
+func (h *healthMonitor) record(name string, err error) {
+	if h == nil {
+		return
+	}
+	var dep dependency
+	for _, d := range h.deps {
+		if d.name == name {
+			dep = d
+		}
+	}
+	status := dependencyStatus{Status: "up", Critical: dep.critical, WhenDown: dep.whenDown, LastChecked: time.Now().UTC()}
+	if err != nil {
+		status.Status = "down"
+		status.Error = err.Error()
+	}
+
+	h.mu.Lock()
+	previous, seen := h.status[name]
+	h.status[name] = status
+	h.mu.Unlock()
+
+	if seen && previous.Status != status.Status || !seen && err != nil {
+		log.Printf("health: %s is %s: %s", name, status.Status, status.Error)
+	}
+}
+
+// up reports whether a dependency passed its latest probe. A nil monitor,
+// as used by replay, treats everything as up.
+func (h *healthMonitor) up(name string) bool {
+	if h == nil {
+		return true
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	status, ok := h.status[name]
+	return !ok || status.Status == "up"
+}
+
+// criticalDown returns the names of critical dependencies that are down
+func (h *healthMonitor) criticalDown() []string {
+	var down []string
+	for _, dep := range h.deps {
+		if dep.critical && !h.up(dep.name) {
+			down = append(down, dep.name)
+		}
+	}
+	return down
+}
+
+// middleware answers student requests with 503 while a critical dependency
+// is down, instead of letting each one time out against it
+func (h *healthMonitor) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, "/students") {
+			c.Next()
+			return
+		}
+		if down := h.criticalDown(); len(down) > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(h.interval.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": fmt.Sprintf("Service unavailable: %s is down", strings.Join(down, ", ")),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// readyz handles GET /readyz. It is 200 while every critical dependency is
+// up, reporting "degraded" if any other is down, and 503 otherwise or until
+// every dependency has been probed once.
+func (h *healthMonitor) readyz(c *gin.Context) {
+	h.mu.RLock()
+	deps := make(map[string]dependencyStatus, len(h.status))
+	for name, status := range h.status {
+		deps[name] = status
+	}
+	h.mu.RUnlock()
+
+	overall, code := "ready", http.StatusOK
+	for _, status := range deps {
+		if status.Status == "up" {
+			continue
+		}
+		if status.Critical {
+			overall, code = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+		overall = "degraded"
+	}
+	if code == http.StatusOK && len(deps) < len(h.deps) {
+		overall, code = "starting", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"status": overall, "dependencies": deps})
+}
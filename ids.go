@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// IDCodec converts internal integer IDs to and from the opaque form exposed
+// in URLs and responses
+type IDCodec interface {
+	Encode(id int) string
+	Decode(public string) (int, error)
+}
+
+// idCodec is nil when IDs are exposed as plain integers
+var idCodec IDCodec
+
+var errInvalidID = errors.New("invalid ID")
+
+// newIDCodec returns the codec selected by ID_CODEC, or nil for plain IDs
+func newIDCodec(c Config) IDCodec {
+	switch c.IDCodec {
+	case "keyed":
+		return newKeyedIDCodec(c.IDCodecSecret)
+	default:
+		return nil
+	}
+}
+
+// publicID returns the form of an internal ID exposed to clients
+func publicID(id int) any {
+	if idCodec == nil {
+		return id
+	}
+	return idCodec.Encode(id)
+}
+
+// parseID converts an ID taken from a URL back to the internal ID
+func parseID(public string) (int, error) {
+	if idCodec == nil {
+		return strconv.Atoi(public)
+	}
+	return idCodec.Decode(public)
+}
+
+// publicStudent is a Student as it crosses the API boundary. Its ID field
+// shadows Student.ID, so responses carry the public ID and request bodies
+// that echo back an encoded id still bind.
+type publicStudent struct {
+	ID any `json:"id"`
+	Student
+}
+
+// present converts a student for a response
+func present(s Student) publicStudent {
+	s.DeletedAt = localTime(s.DeletedAt)
+	return publicStudent{ID: publicID(s.ID), Student: s}
+}
+
+// keyedIDLength is the fixed length of an encoded ID: 11 base62 digits
+// cover every 64-bit value
+const keyedIDLength = 11
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// keyedIDCodec maps IDs through a secret-keyed Feistel permutation of 64-bit
+// values and writes the result in base62. Consecutive IDs produce unrelated
+// strings, and without the secret they cannot be enumerated.
+type keyedIDCodec struct {
+	secret []byte
+}
+
+func newKeyedIDCodec(secret string) keyedIDCodec {
+	return keyedIDCodec{secret: []byte(secret)}
+}
+
+// feistelRounds is enough rounds for a Feistel network with a pseudorandom
+// round function to behave as a pseudorandom permutation
+const feistelRounds = 4
+
+// round is the Feistel round function: a truncated HMAC of the half-block
+func (k keyedIDCodec) round(i int, half uint32) uint32 {
+	var msg [5]byte
+	msg[0] = byte(i)
+	binary.BigEndian.PutUint32(msg[1:], half)
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(msg[:])
+	return binary.BigEndian.Uint32(mac.Sum(nil))
+}
+
+func (k keyedIDCodec) Encode(id int) string {
+	x := uint64(id)
+	l, r := uint32(x>>32), uint32(x)
+	for i := 0; i < feistelRounds; i++ {
+		l, r = r, l^k.round(i, r)
+	}
+	x = uint64(l)<<32 | uint64(r)
+
+	var buf [keyedIDLength]byte
+	for i := keyedIDLength - 1; i >= 0; i-- {
+		buf[i] = base62Alphabet[x%62]
+		x /= 62
+	}
+	return string(buf[:])
+}
+
+func (k keyedIDCodec) Decode(public string) (int, error) {
+	if len(public) != keyedIDLength {
+		return 0, errInvalidID
+	}
+
+	var x uint64
+	for i := 0; i < len(public); i++ {
+		digit := strings.IndexByte(base62Alphabet, public[i])
+		if digit < 0 {
+			return 0, errInvalidID
+		}
+		hi, lo := bits.Mul64(x, 62)
+		lo, carry := bits.Add64(lo, uint64(digit), 0)
+		if hi != 0 || carry != 0 {
+			return 0, errInvalidID
+		}
+		x = lo
+	}
+
+	l, r := uint32(x>>32), uint32(x)
+	for i := feistelRounds - 1; i >= 0; i-- {
+		l, r = r^k.round(i, l), l
+	}
+	x = uint64(l)<<32 | uint64(r)
+
+	if x == 0 || x > math.MaxInt {
+		return 0, errInvalidID
+	}
+	return int(x), nil
+}
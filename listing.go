@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listSort is the order asked for with ?sort= and ?order= on GET /students
+type listSort struct {
+	field string
+	desc  bool
+}
+
+// byID is the order the store lists students in
+var byID = listSort{field: "id"}
+
+// parseSort reads ?sort= and ?order=, answering 400 and returning false if
+// either is invalid
+func parseSort(c *gin.Context) (listSort, bool) {
+	order := listSort{field: strings.ToLower(c.DefaultQuery("sort", "id"))}
+	switch order.field {
+	case "id", "name", "age":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be id, name or age"})
+		return listSort{}, false
+	}
+	switch strings.ToLower(c.DefaultQuery("order", "asc")) {
+	case "asc":
+	case "desc":
+		order.desc = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return listSort{}, false
+	}
+	return order, true
+}
+
+// apply sorts list in place. Names compare case-insensitively, and ties
+// keep ID order.
+func (o listSort) apply(list []Student) {
+	if o == byID {
+		return
+	}
+	slices.SortStableFunc(list, func(a, b Student) int {
+		var cmp int
+		switch o.field {
+		case "name":
+			cmp = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		case "age":
+			cmp = a.Age - b.Age
+		default:
+			cmp = a.ID - b.ID
+		}
+		if o.desc {
+			cmp = -cmp
+		}
+		return cmp
+	})
+}
+
+// listFilter keeps the students matching ?min_age=, ?max_age= and ?name=
+// on GET /students. Zero ages and an empty name match everyone.
+type listFilter struct {
+	minAge, maxAge int
+	name           string
+}
+
+// parseFilter reads the filter parameters, answering 400 and returning
+// false if one is invalid
+func parseFilter(c *gin.Context) (listFilter, bool) {
+	var f listFilter
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{
+		{"min_age", &f.minAge},
+		{"max_age", &f.maxAge},
+	} {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + p.name})
+			return listFilter{}, false
+		}
+		*p.dst = n
+	}
+	if f.maxAge > 0 && f.minAge > f.maxAge {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_age is above max_age"})
+		return listFilter{}, false
+	}
+	f.name = strings.ToLower(strings.TrimSpace(c.Query("name")))
+	return f, true
+}
+
+// apply returns the students in list that match. Names match when they
+// contain f.name, compared case-insensitively.
+func (f listFilter) apply(list []Student) []Student {
+	if f == (listFilter{}) {
+		return list
+	}
+	matched := list[:0]
+	for _, student := range list {
+		if f.minAge > 0 && student.Age < f.minAge {
+			continue
+		}
+		if f.maxAge > 0 && student.Age > f.maxAge {
+			continue
+		}
+		if f.name != "" && !strings.Contains(strings.ToLower(student.Name), f.name) {
+			continue
+		}
+		matched = append(matched, student)
+	}
+	return matched
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// emailPattern finds email addresses the model may have echoed or reworded
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// llmLogEntry is one logged prompt and response
+type llmLogEntry struct {
+	Time       time.Time `json:"time"`
+	StudentID  any       `json:"student_id"`
+	Model      string    `json:"model"`
+	Prompt     string    `json:"prompt"`
+	Response   string    `json:"response,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// llmLog keeps a sample of recent Ollama calls in memory for debugging
+// prompts. Only the newest max entries are kept, and nothing is written to
+// disk. A nil llmLog records nothing.
+type llmLog struct {
+	sampleRate float64
+	redact     bool
+	max        int
+
+	mu      sync.Mutex
+	entries []llmLogEntry
+}
+
+// newLLMLog returns the log configured by c, or nil if LLM_LOG is off
+func newLLMLog(c Config) *llmLog {
+	if !c.LLMLog {
+		return nil
+	}
+	return &llmLog{sampleRate: c.LLMLogSampleRate, redact: c.LLMLogRedact, max: c.LLMLogMaxEntries}
+}
+
+// redactPII replaces the student's name and email, and anything else that
+// looks like an email, with placeholders
+func redactPII(text string, student Student) string {
+	if student.Email != "" {
+		text = strings.ReplaceAll(text, student.Email, "[email]")
+	}
+	text = emailPattern.ReplaceAllString(text, "[email]")
+	if student.Name != "" {
+		text = strings.ReplaceAll(text, student.Name, "[name]")
+		// Models often refer to people by a single name
+		for _, part := range strings.Fields(student.Name) {
+			if len(part) > 1 {
+				text = strings.ReplaceAll(text, part, "[name]")
+			}
+		}
+	}
+	return text
+}
+
+// record logs one call, subject to sampling
+func (l *llmLog) record(student Student, model, prompt, response string, err error, took time.Duration) {
+	if l == nil || rand.Float64() >= l.sampleRate {
+		return
+	}
+
+	entry := llmLogEntry{
+		Time:       time.Now().UTC(),
+		StudentID:  publicID(student.ID),
+		Model:      model,
+		Prompt:     prompt,
+		Response:   response,
+		DurationMS: took.Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if l.redact {
+		entry.Prompt = redactPII(entry.Prompt, student)
+		entry.Response = redactPII(entry.Response, student)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > l.max {
+		l.entries = append(l.entries[:0], l.entries[len(l.entries)-l.max:]...)
+	}
+}
+
+// snapshot returns the entries, newest first
+func (l *llmLog) snapshot() []llmLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]llmLogEntry, len(l.entries))
+	for i, entry := range l.entries {
+		out[len(out)-1-i] = entry
+	}
+	return out
+}
+
+// list handles GET /admin/llm-log
+func (l *llmLog) list(c *gin.Context) {
+	entries := l.snapshot()
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		entries = entries[:min(n, len(entries))]
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "redacted": l.redact, "sample_rate": l.sampleRate})
+}
+
+// writeExport writes every entry as JSON lines, oldest first, for prompt
+// review tools
+func (l *llmLog) writeExport(w io.Writer) error {
+	entries := l.snapshot()
+	enc := json.NewEncoder(w)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := enc.Encode(entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
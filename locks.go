@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lockOwnerHeader identifies the caller acquiring, releasing or holding an edit lock
+const lockOwnerHeader = "X-Lock-Owner"
+
+// lockOwner reads the lock owner a request names, trimmed the same way for
+// taking a lock as for holding one
+func lockOwner(c *gin.Context) string {
+	return strings.TrimSpace(c.GetHeader(lockOwnerHeader))
+}
+
+// EditLock is a short-lived claim on a student record by one editor
+type EditLock struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// activeLock returns the unexpired lock on a student, dropping it if it has
+// expired. The caller must hold s.locksMu.
+func (s *server) activeLock(id int) (EditLock, bool) {
+	lock, ok := s.locks[id]
+	if !ok {
+		return EditLock{}, false
+	}
+	if time.Now().After(lock.ExpiresAt) {
+		delete(s.locks, id)
+		return EditLock{}, false
+	}
+	return lock, true
+}
+
+// checkEditLock reports whether owner may edit the student. Locks held by
+// someone else always block; holding one is only required when
+// REQUIRE_EDIT_LOCK is enabled.
+func (s *server) checkEditLock(id int, owner string) bool {
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+
+	lock, ok := s.activeLock(id)
+	if !ok {
+		return !s.cfg.RequireEditLock
+	}
+	return lock.Owner == owner
+}
+
+// releaseEditLock drops any lock on a student, e.g. once it is deleted
+func (s *server) releaseEditLock(id int) {
+	s.locksMu.Lock()
+	delete(s.locks, id)
+	s.locksMu.Unlock()
+}
+
+// parseLockRequest reads the student ID and lock owner shared by the lock handlers
+func (s *server) parseLockRequest(c *gin.Context) (int, string, bool) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, "", false
+	}
+
+	owner := lockOwner(c)
+	if owner == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": lockOwnerHeader + " header is required"})
+		return 0, "", false
+	}
+
+	if _, err := s.getLive(c.Request.Context(), id); err != nil {
+		respondStoreError(c, err)
+		return 0, "", false
+	}
+
+	return id, owner, true
+}
+
+// acquireEditLock handles POST /students/:id/lock
+func (s *server) acquireEditLock(c *gin.Context) {
+	id, owner, ok := s.parseLockRequest(c)
+	if !ok {
+		return
+	}
+
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+
+	if lock, held := s.activeLock(id); held && lock.Owner != owner {
+		c.JSON(http.StatusConflict, gin.H{"error": "Student is locked by another editor", "lock": lock})
+		return
+	}
+
+	// Acquiring a lock you already hold extends it
+	lock := EditLock{Owner: owner, ExpiresAt: time.Now().Add(s.cfg.EditLockTTL).UTC()}
+	s.locks[id] = lock
+	c.JSON(http.StatusOK, gin.H{"lock": lock})
+}
+
+// getEditLock handles GET /students/:id/lock
+func (s *server) getEditLock(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+
+	lock, ok := s.activeLock(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student is not locked"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lock": lock})
+}
+
+// releaseEditLockHandler handles DELETE /students/:id/lock
+func (s *server) releaseEditLockHandler(c *gin.Context) {
+	id, owner, ok := s.parseLockRequest(c)
+	if !ok {
+		return
+	}
+
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+
+	lock, held := s.activeLock(id)
+	if !held {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student is not locked"})
+		return
+	}
+	if lock.Owner != owner {
+		c.JSON(http.StatusConflict, gin.H{"error": "Student is locked by another editor", "lock": lock})
+		return
+	}
+
+	delete(s.locks, id)
+	c.JSON(http.StatusOK, gin.H{"message": "Lock released"})
+}
@@ -2,13 +2,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"strconv"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,180 +24,554 @@ type Student struct {
 	Name  string `json:"name"`
 	Age   int    `json:"age"`
 	Email string `json:"email"`
+	// Version starts at 1 and goes up with every change. An update that
+	// carries a version must carry the current one.
+	Version int `json:"version"`
+	// DeletedAt is set when the student is soft-deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
-// Global student list and mutex for concurrency
-var (
-	students []Student
-	mu       sync.Mutex
-	nextID   = 1
-)
+// server holds the student store and configuration the handlers run
+// against, along with the state derived from them
+type server struct {
+	store StudentStore
+	cfg   Config
+
+	// mu serializes writes so that checks such as the duplicate email policy
+	// and edit locks see a consistent view
+	mu sync.Mutex
+
+	// Edit locks by student ID, guarded separately from the student list.
+	// When both are needed, mu is always taken before locksMu.
+	locks   map[int]EditLock
+	locksMu sync.Mutex
+
+	// The prefix index is a sorted list of name words, full names and
+	// emails. Writes only mark it stale; it is rebuilt on the next suggest
+	// request, or once it is older than suggestIndexMaxAge. All three are
+	// guarded by mu.
+	suggestIndex      []suggestEntry
+	suggestIndexStale bool
+	suggestIndexBuilt time.Time
+
+	// health is nil when dependencies are not monitored, as in replay
+	health *healthMonitor
+	// llmLog is nil unless LLM_LOG is enabled
+	llmLog *llmLog
+	// slo is nil unless SLO_RULES is set
+	slo *sloTracker
+	// toggles are the settings the admin API can change at runtime
+	toggles *toggles
+	// quotaLevel is how many store quota warning levels were last crossed,
+	// guarded by mu
+	quotaLevel int
+}
+
+// newServer returns a server backed by store
+func newServer(store StudentStore, c Config) *server {
+	return &server{
+		store:             store,
+		cfg:               c,
+		llmLog:            newLLMLog(c),
+		slo:               newSLOTracker(c),
+		toggles:           newToggles(c),
+		locks:             map[int]EditLock{},
+		suggestIndexStale: true,
+	}
+}
 
 func main() {
-	router := gin.Default()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "config":
+			os.Exit(runConfigCommand(os.Args[2:]))
+		case "selftest":
+			os.Exit(runSelftest(os.Args[2:]))
+		case "replay":
+			os.Exit(runReplay(os.Args[2:]))
+		case "anonymize":
+			os.Exit(runAnonymize(os.Args[2:]))
+		case "load-fixtures":
+			os.Exit(runLoadFixtures(os.Args[2:]))
+		case "decrypt-export":
+			os.Exit(runDecryptExport(os.Args[2:]))
+		case "--migrate-only", "-migrate-only":
+			os.Exit(runMigrateOnly())
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+			os.Exit(2)
+		}
+	}
 
-	// Define API endpoints
-	router.POST("/students", createStudent)
-	router.GET("/students", getAllStudents)
-	router.GET("/students/:id", getStudentByID)
-	router.PUT("/students/:id", updateStudent)
-	router.DELETE("/students/:id", deleteStudent)
-	router.GET("/students/:id/summary", getStudentSummary) // New endpoint for summary
+	cfg, err := configure()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Print(startupBanner(cfg))
+
+	store, err := openStoreAtStartup(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	srv := newServer(store, cfg)
+	srv.health = newHealthMonitor(store, cfg)
+	go srv.health.run(context.Background())
+	go srv.runRetention(context.Background())
+	go srv.slo.run(context.Background())
 
-	log.Fatal(router.Run(":8080"))
+	router, err := newRouter(srv)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// On SIGINT or SIGTERM, finish in-flight requests and close the store,
+	// which writes the final snapshot when SNAPSHOT_INTERVAL is set
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	httpServer := &http.Server{Addr: cfg.Addr, Handler: router}
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		log.Print("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
+	log.Printf("Listening and serving HTTP on %s", cfg.Addr)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-drained
+}
+
+// configure loads the configuration and sets up the ID codec and display
+// time zone it selects
+func configure() (Config, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return Config{}, err
+	}
+	idCodec = newIDCodec(cfg)
+	displayZone = cfg.Timezone
+	return cfg, nil
+}
+
+// newRouter builds the API router for s with the middleware selected by its
+// configuration
+func newRouter(s *server) (*gin.Engine, error) {
+	cfg := s.cfg
+	router := gin.New()
+	router.Use(s.toggles.logger(), gin.Recovery())
+	router.Use(versionMiddleware())
+	if cfg.Region != "" {
+		router.Use(regionMiddleware(cfg.Region))
+	}
+	router.GET("/version", getVersion)
+	// Early, so requests refused by the middleware below count too
+	if s.slo != nil {
+		router.Use(s.slo.middleware())
+	}
+	if len(s.cfg.DeprecatedRoutes) > 0 {
+		router.Use(deprecationMiddleware(s.cfg.DeprecatedRoutes))
+	}
+	if s.health != nil {
+		router.GET("/readyz", s.health.readyz)
+		router.Use(s.health.middleware())
+	}
+	var abuse *abuseDetector
+	if cfg.AbuseDetection {
+		abuse = newAbuseDetector(cfg)
+		router.Use(abuse.middleware())
+		for _, path := range honeypotPaths {
+			router.Any(path, abuse.honeypot)
+		}
+	}
+	if cfg.ReadOnly {
+		router.Use(readOnlyMiddleware())
+	}
+	if cfg.RequestSigningSecret != "" {
+		router.Use(signingMiddleware(cfg.RequestSigningSecret, cfg.RequestSigningMaxSkew))
+	}
+	if len(cfg.ChaosRules) > 0 {
+		router.Use(chaosMiddleware(cfg.ChaosRules))
+	}
+
+	// Registered after chaos so requests failed by an injected fault, which
+	// never reached a handler, are not recorded
+	if cfg.ReplayLog != "" {
+		recorder, err := newReplayRecorder(cfg.ReplayLog)
+		if err != nil {
+			return nil, err
+		}
+		recorder.paused = &s.toggles.replayPaused
+		recorder.adminToken = cfg.AdminToken
+		router.Use(recorder.middleware())
+	}
+
+	// Define API endpoints
+	router.POST("/students", s.createStudent)
+	router.POST("/students/batch", s.createStudents)
+	router.PUT("/students/batch", s.updateStudents)
+	router.POST("/students/import", s.importStudents)
+	router.GET("/students", s.getAllStudents)
+	router.DELETE("/students", s.deleteStudents)
+	router.GET("/students/suggest", s.suggestStudents)
+	router.GET("/students/search", s.searchStudents)
+	router.GET("/students/export", s.exportStudentsCSV)
+	router.GET("/students/:id", s.getStudentByID)
+	router.PUT("/students/:id", s.updateStudent)
+	router.PATCH("/students/:id", s.patchStudent)
+	router.DELETE("/students/:id", s.deleteStudent)
+	router.POST("/students/:id/restore", s.restoreStudent)
+	router.GET("/students/:id/summary", s.getStudentSummary) // New endpoint for summary
+	router.POST("/students/:id/lock", s.acquireEditLock)
+	router.GET("/students/:id/lock", s.getEditLock)
+	router.DELETE("/students/:id/lock", s.releaseEditLockHandler)
+	s.registerAdminRoutes(router, abuse)
+
+	return router, nil
 }
 
 // createStudent handles POST /students
-func createStudent(c *gin.Context) {
-	var newStudent Student
-	if err := c.ShouldBindJSON(&newStudent); err != nil {
+func (s *server) createStudent(c *gin.Context) {
+	var body publicStudent
+	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	newStudent := body.Student
+	newStudent.DeletedAt = nil
+	newStudent.Version = 1
 
-	// Input validation
-	if newStudent.Name == "" || newStudent.Age <= 0 || newStudent.Email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+	warnings, ok := s.checkWarnings(c, newStudent)
+	if !ok {
 		return
 	}
 
-	mu.Lock()
-	newStudent.ID = nextID
-	nextID++
-	students = append(students, newStudent)
-	mu.Unlock()
+	ctx := c.Request.Context()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cfg.DuplicateEmailPolicy != EmailPolicyAllow {
+		existing, err := s.store.FindByEmail(ctx, newStudent.Email)
+		switch {
+		case err == nil && s.cfg.DuplicateEmailPolicy == EmailPolicyReject:
+			c.JSON(http.StatusConflict, gin.H{"error": "A student with this email already exists"})
+			return
+		case err == nil:
+			if !s.checkEditLock(existing.ID, lockOwner(c)) {
+				c.JSON(http.StatusLocked, gin.H{"error": "Merging into this student requires holding its edit lock"})
+				return
+			}
+			newStudent.ID = existing.ID
+			newStudent.Version = existing.Version + 1
+			if err := s.store.Update(ctx, newStudent); err != nil {
+				respondStoreError(c, err)
+				return
+			}
+			s.invalidateSuggestIndex()
+			c.JSON(http.StatusOK, withWarnings(gin.H{
+				"message": "Student merged into existing record",
+				"student": present(newStudent),
+			}, warnings))
+			return
+		case !errors.Is(err, ErrNotFound):
+			respondStoreError(c, err)
+			return
+		}
+	}
+
+	newStudent, err := s.store.Create(ctx, newStudent)
+	s.warnQuota(c)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	s.invalidateSuggestIndex()
 
-	c.JSON(http.StatusCreated, gin.H{
+	c.JSON(http.StatusCreated, withWarnings(gin.H{
 		"message": "Student created successfully",
-		"student": newStudent,
-	})
+		"student": present(newStudent),
+	}, warnings))
 }
 
-// getAllStudents handles GET /students
-func getAllStudents(c *gin.Context) {
-	mu.Lock()
-	defer mu.Unlock()
-	c.JSON(http.StatusOK, students)
+// getAllStudents handles GET /students. Soft-deleted students are left out
+// unless an admin asks for them with ?include_deleted=true. ?min_age=,
+// ?max_age= and ?name= filter the list, ?sort= and ?order= set its order,
+// and ?offset= and ?limit= return one page of it.
+func (s *server) getAllStudents(c *gin.Context) {
+	includeDeleted := c.Query("include_deleted") == "true"
+	if includeDeleted && !hasAdminToken(c, s.cfg.AdminToken) {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin token required to include deleted students"})
+		return
+	}
+	filter, ok := parseFilter(c)
+	if !ok {
+		return
+	}
+	order, ok := parseSort(c)
+	if !ok {
+		return
+	}
+	paging, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	if paging.cursor && order != byID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cursor pagination needs the default sort by ascending id"})
+		return
+	}
+
+	list, err := s.store.List(c.Request.Context())
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	if !includeDeleted {
+		list = liveStudents(list)
+	}
+	list = filter.apply(list)
+	order.apply(list)
+	list = paging.apply(c, list)
+
+	body, err := encodeStudentList(list, s.cfg.MaxExportBytes)
+	if errors.Is(err, errExportTooLarge) {
+		respondExportTooLarge(c, s.cfg.MaxExportBytes)
+		return
+	}
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
 }
 
 // getStudentByID handles GET /students/:id
-func getStudentByID(c *gin.Context) {
+func (s *server) getStudentByID(c *gin.Context) {
 	idParam := c.Param("id")
-	id, err := strconv.Atoi(idParam)
+	id, err := parseID(idParam)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-	for _, student := range students {
-		if student.ID == id {
-			c.JSON(http.StatusOK, student)
-			return
-		}
+	student, err := s.getLive(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err)
+		return
 	}
-
-	c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+	c.JSON(http.StatusOK, present(student))
 }
 
 // updateStudent handles PUT /students/:id
-func updateStudent(c *gin.Context) {
+func (s *server) updateStudent(c *gin.Context) {
 	idParam := c.Param("id")
-	id, err := strconv.Atoi(idParam)
+	id, err := parseID(idParam)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	var updatedStudent Student
-	if err := c.ShouldBindJSON(&updatedStudent); err != nil {
+	var body publicStudent
+	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	updatedStudent := body.Student
+	s.saveUpdate(c, id, updatedStudent.Version, func(Student) Student { return updatedStudent })
+}
+
+// saveUpdate replaces the student with id by the result of change, applied
+// to its current values, answering conflicts and lock checks the way PUT
+// and PATCH share. A version other than 0 must match the current one.
+func (s *server) saveUpdate(c *gin.Context, id, version int, change func(current Student) Student) {
+	ctx := c.Request.Context()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.getLive(ctx, id)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	// Without a version the update is applied unconditionally, as before
+	if version != 0 && version != current.Version {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "The student was changed since this version was read",
+			"version": current.Version,
+		})
+		return
+	}
+
+	if !s.checkEditLock(id, lockOwner(c)) {
+		c.JSON(http.StatusLocked, gin.H{"error": "Updating this student requires holding its edit lock"})
+		return
+	}
 
-	// Input validation
-	if updatedStudent.Name == "" || updatedStudent.Age <= 0 || updatedStudent.Email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+	updatedStudent := change(current)
+	updatedStudent.DeletedAt = nil
+	warnings, ok := s.checkWarnings(c, updatedStudent)
+	if !ok {
 		return
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-	for i, student := range students {
-		if student.ID == id {
-			students[i] = updatedStudent
-			students[i].ID = id
-			c.JSON(http.StatusOK, gin.H{"message": "Student updated successfully"})
+	// Only new records can be merged, so both non-allow policies refuse an
+	// update that would make the email collide with another student
+	if s.cfg.DuplicateEmailPolicy != EmailPolicyAllow {
+		existing, err := s.store.FindByEmail(ctx, updatedStudent.Email)
+		if err == nil && existing.ID != id {
+			c.JSON(http.StatusConflict, gin.H{"error": "A student with this email already exists"})
+			return
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			respondStoreError(c, err)
 			return
 		}
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+	updatedStudent.ID = id
+	updatedStudent.Version = current.Version + 1
+	if err := s.store.Update(ctx, updatedStudent); err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	s.invalidateSuggestIndex()
+	c.JSON(http.StatusOK, withWarnings(gin.H{
+		"message": "Student updated successfully",
+		"student": present(updatedStudent),
+	}, warnings))
 }
 
-// deleteStudent handles DELETE /students/:id
-func deleteStudent(c *gin.Context) {
+// deleteStudent handles DELETE /students/:id. The student is only marked
+// deleted, and can be brought back with POST /students/:id/restore.
+func (s *server) deleteStudent(c *gin.Context) {
 	idParam := c.Param("id")
-	id, err := strconv.Atoi(idParam)
+	id, err := parseID(idParam)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-	for i, student := range students {
-		if student.ID == id {
-			students = append(students[:i], students[i+1:]...)
-			c.JSON(http.StatusOK, gin.H{"message": "Student deleted successfully"})
-			return
-		}
+	ctx := c.Request.Context()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	student, err := s.getLive(ctx, id)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	// Millisecond precision is the finest every backend keeps
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	student.DeletedAt = &now
+	student.Version++
+	if err := s.store.Update(ctx, student); err != nil {
+		respondStoreError(c, err)
+		return
 	}
+	s.invalidateSuggestIndex()
+	s.releaseEditLock(id)
+	c.JSON(http.StatusOK, gin.H{"message": "Student deleted successfully"})
+}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+// respondStoreError reports a failed store call, hiding internal errors
+// from the client
+func respondStoreError(c *gin.Context, err error) {
+	if errors.Is(err, ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+		return
+	}
+	if errors.Is(err, ErrStoreFull) {
+		c.JSON(http.StatusInsufficientStorage, gin.H{
+			"error": "The student store is full",
+			"hint":  "Delete students that are no longer needed, raise MEMORY_MAX_STUDENTS, or switch STORE_BACKEND to sqlite or postgres",
+		})
+		return
+	}
+	var limit *batchLimitError
+	if errors.As(err, &limit) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "The batch is too large for one transaction",
+			"hint":  fmt.Sprintf("Send at most %d changes per request with STORE_BACKEND=%s", limit.limit, limit.backend),
+		})
+		return
+	}
+	log.Printf("store: %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	var undo *undoError
+	if errors.As(err, &undo) {
+		ids := make([]any, len(undo.ids))
+		for i, id := range undo.ids {
+			ids[i] = publicID(id)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "The batch failed part-way and could not be undone; these students may hold its changes", "ids": ids})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 }
 
 // getStudentSummary handles GET /students/:id/summary
-func getStudentSummary(c *gin.Context) {
+func (s *server) getStudentSummary(c *gin.Context) {
 	idParam := c.Param("id")
-	id, err := strconv.Atoi(idParam)
+	id, err := parseID(idParam)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
 		return
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-	for _, student := range students {
-		if student.ID == id {
-			summary, err := generateSummary(student)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate summary"})
-				return
-			}
-			c.JSON(http.StatusOK, gin.H{"summary": summary})
+	student, err := s.getLive(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	// While Ollama is down, skip straight to the fallback rather than
+	// making every request wait for it to fail
+	if s.health.up("ollama") {
+		summary, err := s.generateSummary(student)
+		if err == nil {
+			c.JSON(http.StatusOK, gin.H{"summary": summary, "source": "ollama"})
 			return
 		}
+		log.Printf("summary: ollama failed, using fallback: %v", err)
+		s.health.record("ollama", err)
 	}
+	c.JSON(http.StatusOK, gin.H{"summary": fallbackSummary(student), "source": "fallback"})
+}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Student not found"})
+// fallbackSummary describes a student without a language model
+func fallbackSummary(student Student) string {
+	return fmt.Sprintf("%s is a %d-year-old student who can be reached at %s.", student.Name, student.Age, student.Email)
 }
 
 // generateSummary generates a summary of a student's profile using Ollama API
-func generateSummary(student Student) (string, error) {
-	prompt := fmt.Sprintf("Summarize the following student profile:\n\nID: %d\nName: %s\nAge: %d\nEmail: %s",
-		student.ID, student.Name, student.Age, student.Email)
+func (s *server) generateSummary(student Student) (string, error) {
+	prompt := fmt.Sprintf("Summarize the following student profile:\n\nID: %v\nName: %s\nAge: %d\nEmail: %s",
+		publicID(student.ID), student.Name, student.Age, student.Email)
+
+	start := time.Now()
+	summary, err := s.ollamaGenerate(prompt)
+	s.llmLog.record(student, s.cfg.OllamaModel, prompt, summary, err, time.Since(start))
+	return summary, err
+}
 
+// ollamaGenerate sends a prompt to the Ollama generate API and returns its response
+func (s *server) ollamaGenerate(prompt string) (string, error) {
 	requestBody, err := json.Marshal(map[string]string{
 		"prompt": prompt,
-		"model":  "llama2", // Replace with your actual model name
+		"model":  s.cfg.OllamaModel,
 	})
 	if err != nil {
 		return "", err
 	}
 
-	resp, err := http.Post("http://localhost:11434/api/generate", "application/json", bytes.NewBuffer(requestBody))
+	resp, err := http.Post(s.cfg.OllamaURL+"/api/generate", "application/json", bytes.NewBuffer(requestBody))
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationFiles holds the schema migrations of each SQL dialect, in
+// migrations/<dialect>/<version>_<name>.sql. Applied migrations must never
+// be edited; change the schema by adding a file with the next version.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migration is one versioned schema change
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations reads a dialect's migrations ordered by version
+func loadMigrations(dialect string) ([]migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read %s migrations: %w", dialect, err)
+	}
+
+	var list []migration
+	for _, entry := range entries {
+		base, ok := strings.CutSuffix(entry.Name(), ".sql")
+		if !ok {
+			continue
+		}
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s/%s: name must start with a positive version number", dialect, entry.Name())
+		}
+		raw, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, migration{version: version, name: name, sql: string(raw)})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].version < list[j].version })
+	for i := 1; i < len(list); i++ {
+		if list[i].version == list[i-1].version {
+			return nil, fmt.Errorf("%s migrations: version %d is used twice", dialect, list[i].version)
+		}
+	}
+	return list, nil
+}
+
+// splitStatements splits a migration into statements at lines ending in a
+// semicolon, since not every driver runs several statements in one Exec
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(script, "\n") {
+		current.WriteString(line)
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			if stmt := strings.TrimSpace(current.String()); stmt != "" {
+				statements = append(statements, stmt)
+			}
+			current.Reset()
+		}
+	}
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
+
+// lockMigrations takes the dialect's migration lock on conn. A lock that
+// reports its result, like MySQL's GET_LOCK, must return 1; 0 means it
+// timed out waiting for another instance, and NULL that it failed.
+func lockMigrations(ctx context.Context, conn *sql.Conn, d sqlDialect) error {
+	if !d.lockReturnsResult {
+		_, err := conn.ExecContext(ctx, d.lockMigrations)
+		return err
+	}
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, d.lockMigrations).Scan(&got); err != nil {
+		return err
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return errors.New("another instance is holding the lock; try again once it has finished migrating")
+	}
+	return nil
+}
+
+// migrate applies the dialect's pending migrations, each in its own
+// transaction together with its schema_migrations row
+func migrate(ctx context.Context, db *sql.DB, d sqlDialect) error {
+	migrations, err := loadMigrations(d.name)
+	if err != nil {
+		return err
+	}
+
+	// One connection throughout, so a session-level migration lock holds
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if d.lockMigrations != "" {
+		if err := lockMigrations(ctx, conn, d); err != nil {
+			return fmt.Errorf("lock %s migrations: %w", d.name, err)
+		}
+		defer conn.ExecContext(context.WithoutCancel(ctx), d.unlockMigrations)
+	}
+
+	if _, err := conn.ExecContext(ctx, d.createMigrationsTable); err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+
+	applied := map[int]bool{}
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("read applied %s migrations: %w", d.name, err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMigration(ctx, conn, d, m); err != nil {
+			return fmt.Errorf("apply %s migration %04d_%s: %w", d.name, m.version, m.name, err)
+		}
+		log.Printf("migrate: applied %s migration %04d_%s", d.name, m.version, m.name)
+	}
+	return nil
+}
+
+// applyMigration runs one migration and records it
+func applyMigration(ctx context.Context, conn *sql.Conn, d sqlDialect, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(m.sql) {
+		_, err := tx.ExecContext(ctx, stmt)
+		if err != nil && d.repeatedDDL != nil && d.repeatedDDL(err) {
+			log.Printf("migrate: %s migration %04d_%s: skipping a change that was already made: %v", d.name, m.version, m.name, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, d.recordMigration, m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// runMigrateOnly implements --migrate-only: it opens the configured store,
+// which applies any pending migrations, and exits. It returns the exit code.
+func runMigrateOnly() int {
+	cfg, err := configure()
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	store, err := openStoreAtStartup(cfg)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	store.Close()
+	log.Printf("migrate: %s store is up to date", cfg.StoreBackend)
+	return 0
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS students (
+	id    BIGINT       NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	name  VARCHAR(255) NOT NULL,
+	age   INT          NOT NULL,
+	email VARCHAR(320) NOT NULL COLLATE utf8mb4_unicode_ci,
+	INDEX students_email (email)
+) DEFAULT CHARSET = utf8mb4;
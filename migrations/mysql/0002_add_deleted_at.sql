@@ -0,0 +1 @@
+ALTER TABLE students ADD COLUMN deleted_at DATETIME(6) NULL;
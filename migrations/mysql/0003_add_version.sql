@@ -0,0 +1 @@
+ALTER TABLE students ADD COLUMN version INT NOT NULL DEFAULT 0;
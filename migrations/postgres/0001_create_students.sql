@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS students (
+	id    BIGSERIAL PRIMARY KEY,
+	name  TEXT    NOT NULL,
+	age   INTEGER NOT NULL,
+	email TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS students_email ON students (lower(email));
@@ -0,0 +1,2 @@
+ALTER TABLE students ALTER COLUMN version SET DEFAULT 1;
+UPDATE students SET version = 1 WHERE version = 0;
@@ -0,0 +1 @@
+UPDATE students SET version = 1 WHERE version = 0;
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPageLimit caps ?limit= on GET /students
+const maxPageLimit = 1000
+
+// page is the slice of a list asked for with ?offset= and ?limit=, or with
+// ?cursor= and ?limit=. A zero limit means the whole list from the start of
+// the page on.
+type page struct {
+	offset, limit int
+
+	// With cursor set the page starts after the student with ID after,
+	// which stays correct while students are added and removed
+	cursor bool
+	after  int
+}
+
+// encodeCursor makes the opaque cursor that continues after id. It holds
+// the public ID, so it reveals no more than the responses do.
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprint(publicID(id))))
+}
+
+// decodeCursor returns the ID a cursor continues after
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return parseID(string(raw))
+}
+
+// parsePage reads ?offset= and ?limit=, answering 400 and returning false
+// if either is invalid
+func parsePage(c *gin.Context) (page, bool) {
+	var p page
+	if raw := c.Query("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+			return page{}, false
+		}
+		p.offset = n
+	}
+	if raw, ok := c.GetQuery("cursor"); ok {
+		if c.Query("offset") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Use either cursor or offset, not both"})
+			return page{}, false
+		}
+		p.cursor = true
+		if raw != "" {
+			after, err := decodeCursor(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+				return page{}, false
+			}
+			p.after = after
+		}
+	}
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return page{}, false
+		}
+		p.limit = min(n, maxPageLimit)
+	}
+	return p, true
+}
+
+// apply returns the page of list, which must be ordered by ID in cursor
+// mode, and describes it in the response headers: X-Total-Count, then
+// X-Next-Cursor, or X-Next-Offset and X-Prev-Offset, when there is such a
+// page, and a Link header with the same pages as URLs
+func (p page) apply(c *gin.Context, list []Student) []Student {
+	total := len(list)
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	if p.cursor {
+		return p.applyCursor(c, list)
+	}
+	start := min(p.offset, total)
+	end := total
+	if p.limit > 0 {
+		end = min(start+p.limit, total)
+	}
+
+	var links []string
+	if end < total {
+		c.Header("X-Next-Offset", strconv.Itoa(end))
+		links = append(links, p.link(c, end, "next"))
+	}
+	if start > 0 && p.limit > 0 {
+		prev := max(start-p.limit, 0)
+		c.Header("X-Prev-Offset", strconv.Itoa(prev))
+		links = append(links, p.link(c, prev, "prev"))
+	}
+	if len(links) > 0 {
+		c.Writer.Header().Add("Link", strings.Join(links, ", "))
+	}
+	return list[start:end]
+}
+
+// applyCursor returns the page of list after p.after
+func (p page) applyCursor(c *gin.Context, list []Student) []Student {
+	start := sort.Search(len(list), func(i int) bool { return list[i].ID > p.after })
+	end := len(list)
+	if p.limit > 0 {
+		end = min(start+p.limit, len(list))
+	}
+	if end < len(list) {
+		next := encodeCursor(list[end-1].ID)
+		c.Header("X-Next-Cursor", next)
+		query := c.Request.URL.Query()
+		query.Set("cursor", next)
+		c.Writer.Header().Add("Link", pageLink(c, query, "next"))
+	}
+	return list[start:end]
+}
+
+// link is a Link header entry for the request's URL at offset
+func (p page) link(c *gin.Context, offset int, rel string) string {
+	query := c.Request.URL.Query()
+	query.Set("offset", strconv.Itoa(offset))
+	query.Set("limit", strconv.Itoa(p.limit))
+	return pageLink(c, query, rel)
+}
+
+// pageLink is a Link header entry for the request's path with query
+func pageLink(c *gin.Context, query url.Values, rel string) string {
+	return "<" + c.Request.URL.Path + "?" + query.Encode() + `>; rel="` + rel + `"`
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// studentPatch holds the fields of a PATCH body that were sent
+type studentPatch struct {
+	Name    *string
+	Age     *int
+	Email   *string
+	Version int
+}
+
+// parseStudentPatch decodes a partial student, or a JSON Merge Patch
+// (RFC 7396) of one, which is the same thing for a flat object. Removing a
+// field with null is refused since every field is required. It returns
+// the problems found by field.
+func parseStudentPatch(fields map[string]json.RawMessage) (studentPatch, map[string]string) {
+	var patch studentPatch
+	problems := map[string]string{}
+	for name, raw := range fields {
+		if string(raw) == "null" {
+			problems[name] = "cannot be removed"
+			continue
+		}
+		switch name {
+		case "name":
+			if json.Unmarshal(raw, &patch.Name) != nil || *patch.Name == "" {
+				problems[name] = "must be a non-empty string"
+			}
+		case "age":
+			if json.Unmarshal(raw, &patch.Age) != nil || *patch.Age <= 0 {
+				problems[name] = "must be a positive integer"
+			}
+		case "email":
+			if json.Unmarshal(raw, &patch.Email) != nil || *patch.Email == "" {
+				problems[name] = "must be a non-empty string"
+			}
+		case "version":
+			if json.Unmarshal(raw, &patch.Version) != nil || patch.Version < 0 {
+				problems[name] = "must be a version number"
+			}
+		case "id":
+			// Echoed back from a read; the ID comes from the path
+		default:
+			problems[name] = "is not a student field"
+		}
+	}
+	return patch, problems
+}
+
+// patchStudent handles PATCH /students/:id, updating only the fields in
+// the body
+func (s *server) patchStudent(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&fields); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	patch, problems := parseStudentPatch(fields)
+	if len(problems) > 0 {
+		names := make([]string, 0, len(problems))
+		for name := range problems {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data: " + names[0] + " " + problems[names[0]], "fields": problems})
+		return
+	}
+
+	s.saveUpdate(c, id, patch.Version, func(student Student) Student {
+		if patch.Name != nil {
+			student.Name = *patch.Name
+		}
+		if patch.Age != nil {
+			student.Age = *patch.Age
+		}
+		if patch.Email != nil {
+			student.Email = *patch.Email
+		}
+		return student
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnlyAdminRoutes are the admin routes that change student data, and so
+// stay blocked on a read-only instance
+var readOnlyAdminRoutes = map[string]bool{
+	"/admin/retention/purge":      true,
+	"/admin/migrate-email-domain": true,
+}
+
+// readOnlyMiddleware rejects every request that could modify data, for
+// instances serving reads from a replicated data source. Other admin
+// actions, such as unblocking a client or changing a toggle, only affect
+// this instance and are still allowed.
+func readOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if strings.HasPrefix(c.Request.URL.Path, "/admin/") && !readOnlyAdminRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+		c.Header("Allow", "GET, HEAD, OPTIONS")
+		c.AbortWithStatusJSON(http.StatusMethodNotAllowed, gin.H{"error": "This instance is read-only"})
+	}
+}
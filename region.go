@@ -0,0 +1,59 @@
+package main
+
+import (
+	"expvar"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// regionHeader carries the instance's REGION on every response, so clients
+// and load balancers can tell which region served them
+const regionHeader = "X-Region"
+
+// regionMetrics holds the request counts and latency histogram of this
+// instance under its region, so the metrics of instances in several
+// regions can be collected together and told apart
+var regionMetrics = expvar.NewMap("region_metrics")
+
+// regionLatencyBuckets are the upper bounds of the latency histogram
+var regionLatencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// regionMiddleware adds the X-Region header to every response and records
+// the request in regionMetrics under region
+func regionMiddleware(region string) gin.HandlerFunc {
+	metrics := new(expvar.Map).Init()
+	regionMetrics.Set(region, metrics)
+	bucketKeys := make([]string, len(regionLatencyBuckets))
+	for i, bound := range regionLatencyBuckets {
+		bucketKeys[i] = "latency_ms_le_" + strconv.FormatInt(bound.Milliseconds(), 10)
+	}
+
+	return func(c *gin.Context) {
+		c.Header(regionHeader, region)
+		start := time.Now()
+		c.Next()
+		took := time.Since(start)
+
+		metrics.Add("requests_total", 1)
+		if c.Writer.Status() >= 500 {
+			metrics.Add("errors_total", 1)
+		}
+		metrics.Add("latency_us_sum", took.Microseconds())
+		// Buckets are cumulative, as in a Prometheus histogram
+		for i, bound := range regionLatencyBuckets {
+			if took <= bound {
+				metrics.Add(bucketKeys[i], 1)
+			}
+		}
+	}
+}
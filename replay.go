@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// replayHeaders are the request headers that can change a handler's outcome
+// and so are kept in the replay log. The admin token is not one of them: it
+// is only noted that a request carried it, see replayEntry.Admin.
+var replayHeaders = []string{"Content-Type", lockOwnerHeader}
+
+// replayEntry is one recorded mutating request, written as a JSON line
+type replayEntry struct {
+	Time   time.Time         `json:"time"`
+	Method string            `json:"method"`
+	Path   string            `json:"path"`
+	Header map[string]string `json:"header,omitempty"`
+	// Admin is set when the request carried the ADMIN_TOKEN bearer token.
+	// Replay sends its own ADMIN_TOKEN in its place.
+	Admin  bool   `json:"admin,omitempty"`
+	Body   string `json:"body,omitempty"`
+	Status int    `json:"status"`
+}
+
+// replayRecorder appends mutating requests to the replay log
+type replayRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+	// paused, if set, turns recording off while it is true
+	paused *atomic.Bool
+	// adminToken is the ADMIN_TOKEN that marks a request as Admin
+	adminToken string
+}
+
+// newReplayRecorder opens path for appending, creating it if needed
+func newReplayRecorder(path string) (*replayRecorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open replay log: %w", err)
+	}
+	return &replayRecorder{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// middleware records every non-GET request together with the status it got
+func (r *replayRecorder) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if r.paused != nil && r.paused.Load() {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		entry := replayEntry{
+			Time:   time.Now().UTC(),
+			Method: c.Request.Method,
+			Path:   c.Request.URL.RequestURI(),
+			Admin:  hasAdminToken(c, r.adminToken),
+			Body:   string(body),
+		}
+		for _, name := range replayHeaders {
+			if value := c.GetHeader(name); value != "" {
+				if entry.Header == nil {
+					entry.Header = map[string]string{}
+				}
+				entry.Header[name] = value
+			}
+		}
+
+		c.Next()
+
+		entry.Status = c.Writer.Status()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if err := r.enc.Encode(entry); err != nil {
+			log.Printf("replay: failed to record %s %s: %v", entry.Method, entry.Path, err)
+		}
+	}
+}
+
+// runReplay implements the replay subcommand: it applies a replay log to a
+// fresh in-memory dataset and reports requests whose status differs from
+// the recorded one. It returns the exit code.
+func runReplay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	dump := flags.Bool("dump", false, "print the resulting students as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: replay [-dump] <replay-log>")
+		return 2
+	}
+
+	cfg, err := configure()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// Replay runs the handlers as configured, without faults or re-recording.
+	// Recorded requests carry no signatures, and their nonces would be spent.
+	cfg.ChaosRules = nil
+	cfg.ReplayLog = ""
+	cfg.RequestSigningSecret = ""
+
+	// Always start from an empty dataset, whatever backend is configured
+	srv := newServer(newMemoryStore(cfg.MemoryMaxStudents), cfg)
+
+	gin.SetMode(gin.ReleaseMode)
+	gin.DefaultWriter = io.Discard
+	router, err := newRouter(srv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	applied, mismatches, skipped := 0, 0, 0
+	dec := json.NewDecoder(file)
+	for {
+		var entry replayEntry
+		if err := dec.Decode(&entry); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "entry %d: %v\n", applied+skipped+1, err)
+			return 1
+		}
+
+		if entry.Admin && cfg.AdminToken == "" {
+			skipped++
+			fmt.Printf("entry %d: %s %s skipped: it was made with the admin token, and ADMIN_TOKEN is unset\n",
+				applied+skipped, entry.Method, entry.Path)
+			continue
+		}
+		req := httptest.NewRequest(entry.Method, entry.Path, strings.NewReader(entry.Body))
+		for name, value := range entry.Header {
+			req.Header.Set(name, value)
+		}
+		if entry.Admin {
+			req.Header.Set("Authorization", "Bearer "+cfg.AdminToken)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		applied++
+
+		if rec.Code != entry.Status {
+			mismatches++
+			fmt.Printf("entry %d: %s %s got status %d, recorded %d: %s\n",
+				applied+skipped, entry.Method, entry.Path, rec.Code, entry.Status, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+
+	fmt.Printf("replayed %d request(s), %d status mismatch(es), %d admin request(s) skipped\n", applied, mismatches, skipped)
+
+	if *dump {
+		list, err := srv.store.List(context.Background())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		out, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(string(out))
+	}
+
+	if mismatches > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// retentionPurged counts students permanently removed by the retention policy
+var retentionPurged = expvar.NewInt("retention_purged_total")
+
+// purgeDeleted permanently deletes the students soft-deleted more than
+// olderThan ago and returns their IDs
+func (s *server) purgeDeleted(ctx context.Context, olderThan time.Duration) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	purged := []int{}
+	for _, student := range list {
+		if student.DeletedAt == nil || !student.DeletedAt.Before(cutoff) {
+			continue
+		}
+		if err := s.store.Delete(ctx, student.ID); err != nil {
+			return purged, err
+		}
+		retentionPurged.Add(1)
+		purged = append(purged, student.ID)
+	}
+	return purged, nil
+}
+
+// runRetention purges expired soft-deleted students every
+// RETENTION_INTERVAL until ctx is done, skipping runs outside
+// RETENTION_WINDOW in TIMEZONE. It does nothing unless RETENTION_PERIOD is
+// set.
+func (s *server) runRetention(ctx context.Context) {
+	if s.cfg.RetentionPeriod <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.RetentionInterval)
+	defer ticker.Stop()
+	for {
+		if window := s.cfg.RetentionWindow; window == nil || window.contains(time.Now(), s.cfg.Timezone) {
+			purged, err := s.purgeDeleted(ctx, s.cfg.RetentionPeriod)
+			if err != nil {
+				log.Printf("retention: purge failed after %d student(s): %v", len(purged), err)
+			} else if len(purged) > 0 {
+				log.Printf("retention: purged %d student(s) deleted more than %s ago", len(purged), s.cfg.RetentionPeriod)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// triggerPurge handles POST /admin/retention/purge, running a purge now.
+// ?older_than= overrides RETENTION_PERIOD for this run.
+func (s *server) triggerPurge(c *gin.Context) {
+	olderThan := s.cfg.RetentionPeriod
+	if raw := c.Query("older_than"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "older_than must be a duration such as 720h"})
+			return
+		}
+		olderThan = d
+	} else if olderThan <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "RETENTION_PERIOD is not set; pass older_than"})
+		return
+	}
+
+	purged, err := s.purgeDeleted(c.Request.Context(), olderThan)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	ids := make([]any, len(purged))
+	for i, id := range purged {
+		ids[i] = publicID(id)
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": len(purged), "ids": ids, "older_than": olderThan.String()})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Search results are capped like suggestions, but deeper
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Points a query term scores, by how it matches. Name matches rank above
+// email matches of the same kind.
+const (
+	scoreNameWord    = 6
+	scoreNamePrefix  = 4
+	scoreEmailWord   = 3
+	scoreNameContain = 2
+	scoreEmailPrefix = 2
+	scoreContain     = 1
+)
+
+// searchTerms splits text into lowercased words of letters and digits
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// termScore is how well term matches one of words, 0 if it does not
+func termScore(term string, words []string, word, prefix, contain int) int {
+	best := 0
+	for _, w := range words {
+		switch {
+		case w == term:
+			return word
+		case strings.HasPrefix(w, term):
+			best = max(best, prefix)
+		case strings.Contains(w, term):
+			best = max(best, contain)
+		}
+	}
+	return best
+}
+
+// searchScore ranks a student against the query terms. Every term has to
+// match the name or the email, or the score is 0.
+func searchScore(student Student, terms []string) int {
+	name := searchTerms(student.Name)
+	email := searchTerms(student.Email)
+	total := 0
+	for _, term := range terms {
+		score := max(
+			termScore(term, name, scoreNameWord, scoreNamePrefix, scoreNameContain),
+			termScore(term, email, scoreEmailWord, scoreEmailPrefix, scoreContain),
+		)
+		if score == 0 {
+			return 0
+		}
+		total += score
+	}
+	return total
+}
+
+// searchStudents handles GET /students/search, finding students whose name
+// and email match every word of ?q=, best matches first
+func (s *server) searchStudents(c *gin.Context) {
+	terms := searchTerms(c.Query("q"))
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q must contain a word to search for"})
+		return
+	}
+	limit := defaultSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(n, maxSearchLimit)
+	}
+
+	list, err := s.store.List(c.Request.Context())
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+
+	type result struct {
+		publicStudent
+		Score int `json:"score"`
+	}
+	results := []result{}
+	for _, student := range liveStudents(list) {
+		if score := searchScore(student, terms); score > 0 {
+			results = append(results, result{present(student), score})
+		}
+	}
+	// The list is in ID order, so equal scores stay in ID order
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+
+	total := len(results)
+	c.JSON(http.StatusOK, gin.H{"results": results[:min(limit, total)], "total": total})
+}
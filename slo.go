@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sloBuckets is how many buckets the rolling window is split into. Old
+// buckets drop out whole, so the window moves in steps of window/sloBuckets.
+const sloBuckets = 60
+
+// sloMinRequests is the fewest requests in the window before a burning
+// budget is alerted on, so one early failure does not page anyone
+const sloMinRequests = 20
+
+// SLORule sets objectives for one route
+type SLORule struct {
+	// Route is a method and Gin route pattern such as "GET /students/:id",
+	// or "*" for all routes together
+	Route string `json:"route"`
+	// Availability is the fraction of requests that must not fail with a
+	// 5xx status, e.g. 0.999
+	Availability float64 `json:"availability,omitempty"`
+	// Latency and LatencyTarget require that fraction of requests to finish
+	// within the latency, e.g. "300ms" and 0.99
+	Latency       string  `json:"latency,omitempty"`
+	LatencyTarget float64 `json:"latency_target,omitempty"`
+
+	latency time.Duration
+}
+
+// parseSLORules parses and validates the SLO_RULES JSON array
+func parseSLORules(raw string) ([]SLORule, error) {
+	var rules []SLORule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("SLO_RULES must be a JSON array of rules: %v", err)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Route == "" {
+			return nil, fmt.Errorf("SLO_RULES[%d]: route is required", i)
+		}
+		for _, target := range []float64{rule.Availability, rule.LatencyTarget} {
+			if target < 0 || target >= 1 {
+				return nil, fmt.Errorf("SLO_RULES[%d]: targets must be at least 0 and below 1", i)
+			}
+		}
+		if rule.Availability == 0 && rule.LatencyTarget == 0 {
+			return nil, fmt.Errorf("SLO_RULES[%d]: set availability, latency_target or both", i)
+		}
+		if rule.LatencyTarget > 0 {
+			d, err := time.ParseDuration(rule.Latency)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("SLO_RULES[%d]: latency_target needs a latency such as 300ms (got %q)", i, rule.Latency)
+			}
+			rule.latency = d
+		}
+	}
+
+	return rules, nil
+}
+
+// sloBucket counts the requests in one slice of the window
+type sloBucket struct {
+	slot   int64
+	total  int64
+	errors int64
+	slow   int64
+}
+
+// sloSeries is the rolling window of one rule
+type sloSeries struct {
+	rule SLORule
+
+	mu       sync.Mutex
+	buckets  [sloBuckets]sloBucket
+	alerting bool
+}
+
+// sloTracker measures each request against the rules for its route and
+// logs when a route burns its error budget faster than SLO_ALERT_BURN_RATE
+type sloTracker struct {
+	window    time.Duration
+	burnAlert float64
+	series    []*sloSeries
+}
+
+// newSLOTracker returns the tracker for c.SLORules, or nil if there are none
+func newSLOTracker(c Config) *sloTracker {
+	if len(c.SLORules) == 0 {
+		return nil
+	}
+	t := &sloTracker{window: c.SLOWindow, burnAlert: c.SLOAlertBurnRate}
+	for _, rule := range c.SLORules {
+		t.series = append(t.series, &sloSeries{rule: rule})
+	}
+	return t
+}
+
+// slot is the bucket sequence number of a time
+func (t *sloTracker) slot(now time.Time) int64 {
+	return now.UnixNano() / int64(t.window/sloBuckets)
+}
+
+// add counts one request
+func (s *sloSeries) add(slot int64, failed, slow bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &s.buckets[slot%sloBuckets]
+	if b.slot != slot {
+		*b = sloBucket{slot: slot}
+	}
+	b.total++
+	if failed {
+		b.errors++
+	}
+	if slow {
+		b.slow++
+	}
+}
+
+// sum totals the buckets still inside the window ending at slot
+func (s *sloSeries) sum(slot int64) sloBucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total sloBucket
+	for _, b := range s.buckets {
+		if b.slot > slot-sloBuckets && b.slot <= slot {
+			total.total += b.total
+			total.errors += b.errors
+			total.slow += b.slow
+		}
+	}
+	return total
+}
+
+// middleware records every request against the matching rules
+func (t *sloTracker) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		took := time.Since(start)
+
+		route := c.Request.Method + " " + c.FullPath()
+		failed := c.Writer.Status() >= 500
+		slot := t.slot(time.Now())
+		for _, s := range t.series {
+			if s.rule.Route == "*" || s.rule.Route == route {
+				s.add(slot, failed, s.rule.latency > 0 && took > s.rule.latency)
+			}
+		}
+	}
+}
+
+// sloObjective is the compliance with one objective over the window
+type sloObjective struct {
+	Target float64 `json:"target"`
+	Actual float64 `json:"actual"`
+	Met    bool    `json:"met"`
+	// BudgetRemaining is the fraction of the error budget left, negative
+	// once it is overspent
+	BudgetRemaining float64 `json:"budget_remaining"`
+	// BurnRate is how fast the budget is being spent, where 1 spends it
+	// exactly over the window
+	BurnRate float64 `json:"burn_rate"`
+}
+
+// sloStatus is one rule's report entry
+type sloStatus struct {
+	Route        string        `json:"route"`
+	Requests     int64         `json:"requests"`
+	Availability *sloObjective `json:"availability,omitempty"`
+	Latency      *sloObjective `json:"latency,omitempty"`
+	LatencyLimit string        `json:"latency_limit,omitempty"`
+}
+
+// objective computes compliance with target given bad events out of total.
+// With no requests every objective is met.
+func objective(target float64, bad, total int64) *sloObjective {
+	o := &sloObjective{Target: target, Actual: 1, Met: true, BudgetRemaining: 1}
+	if total == 0 {
+		return o
+	}
+	badFraction := float64(bad) / float64(total)
+	o.Actual = 1 - badFraction
+	o.Met = o.Actual >= target
+	o.BurnRate = badFraction / (1 - target)
+	o.BudgetRemaining = 1 - o.BurnRate
+	return o
+}
+
+// status reports one rule over the window ending now
+func (t *sloTracker) status(s *sloSeries, now time.Time) sloStatus {
+	sum := s.sum(t.slot(now))
+	status := sloStatus{Route: s.rule.Route, Requests: sum.total}
+	if s.rule.Availability > 0 {
+		status.Availability = objective(s.rule.Availability, sum.errors, sum.total)
+	}
+	if s.rule.LatencyTarget > 0 {
+		status.Latency = objective(s.rule.LatencyTarget, sum.slow, sum.total)
+		status.LatencyLimit = s.rule.latency.String()
+	}
+	return status
+}
+
+// run checks the burn rates once per bucket until ctx is done. A nil
+// tracker does nothing.
+func (t *sloTracker) run(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	ticker := time.NewTicker(t.window / sloBuckets)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.checkBurn(time.Now())
+		}
+	}
+}
+
+// checkBurn logs each rule that starts or stops burning its budget faster
+// than the alert rate
+func (t *sloTracker) checkBurn(now time.Time) {
+	for _, s := range t.series {
+		status := t.status(s, now)
+		burn := 0.0
+		for _, o := range []*sloObjective{status.Availability, status.Latency} {
+			if o != nil {
+				burn = max(burn, o.BurnRate)
+			}
+		}
+		burning := status.Requests >= sloMinRequests && burn > t.burnAlert
+
+		s.mu.Lock()
+		changed := burning != s.alerting
+		s.alerting = burning
+		s.mu.Unlock()
+		switch {
+		case changed && burning:
+			log.Printf("slo: ALERT %s is burning its error budget %.1fx faster than allowed over the last %s", s.rule.Route, burn, t.window)
+		case changed:
+			log.Printf("slo: resolved: %s is back within its error budget burn rate", s.rule.Route)
+		}
+	}
+}
+
+// report handles GET /admin/slo
+func (t *sloTracker) report(c *gin.Context) {
+	now := time.Now()
+	routes := make([]sloStatus, 0, len(t.series))
+	for _, s := range t.series {
+		routes = append(routes, t.status(s, now))
+	}
+	c.JSON(http.StatusOK, gin.H{"window": t.window.String(), "alert_burn_rate": t.burnAlert, "routes": routes})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getLive returns the student with id, treating a soft-deleted one as not
+// found
+func (s *server) getLive(ctx context.Context, id int) (Student, error) {
+	student, err := s.store.Get(ctx, id)
+	if err != nil {
+		return Student{}, err
+	}
+	if student.DeletedAt != nil {
+		return Student{}, ErrNotFound
+	}
+	return student, nil
+}
+
+// liveStudents drops the soft-deleted students from list
+func liveStudents(list []Student) []Student {
+	live := make([]Student, 0, len(list))
+	for _, student := range list {
+		if student.DeletedAt == nil {
+			live = append(live, student)
+		}
+	}
+	return live
+}
+
+// restoreStudent handles POST /students/:id/restore, undoing a soft delete
+func (s *server) restoreStudent(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	student, err := s.store.Get(ctx, id)
+	if err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	if student.DeletedAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Student is not deleted"})
+		return
+	}
+
+	// The email may have been taken while the student was deleted
+	if s.cfg.DuplicateEmailPolicy != EmailPolicyAllow {
+		_, err := s.store.FindByEmail(ctx, student.Email)
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "A student with this email already exists"})
+			return
+		}
+		if !errors.Is(err, ErrNotFound) {
+			respondStoreError(c, err)
+			return
+		}
+	}
+
+	student.DeletedAt = nil
+	student.Version++
+	if err := s.store.Update(ctx, student); err != nil {
+		respondStoreError(c, err)
+		return
+	}
+	s.invalidateSuggestIndex()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Student restored successfully",
+		"student": present(student),
+	})
+}
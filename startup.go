@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// retryStartup runs check until it succeeds, making up to
+// STARTUP_ATTEMPTS attempts and doubling the delay between them. Errors
+// that do not look like a connection failure are returned at once, since
+// retrying a bad setting fails the same way every time. The final error
+// carries hint, which should tell the operator what to fix.
+func retryStartup(c Config, name, hint string, check func() error) error {
+	delay := c.StartupRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := check()
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("startup: %s is available after %d attempts", name, attempt)
+			}
+			return nil
+		}
+		if !isConnectionError(err) {
+			return fmt.Errorf("startup: %s: %w; %s", name, err, hint)
+		}
+		if attempt == c.StartupAttempts {
+			return fmt.Errorf("startup: %s is not available after %d attempt(s): %w; %s", name, attempt, err, hint)
+		}
+		log.Printf("startup: %s is not available (attempt %d/%d): %v; retrying in %s", name, attempt, c.StartupAttempts, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isConnectionError reports whether err looks like a service that is not
+// reachable yet: a network error, a refused connection or a timeout
+func isConnectionError(err error) bool {
+	// A url.Error wraps every failed HTTP request, reachable or not
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// openStoreAtStartup opens the configured store, retrying while it is
+// unreachable, and optionally waits for Ollama too, so a bad deployment
+// fails at boot rather than on its first request
+func openStoreAtStartup(c Config) (StudentStore, error) {
+	var store StudentStore
+	err := retryStartup(c, c.StoreBackend+" store", "check STORE_BACKEND and its connection settings", func() error {
+		var err error
+		store, err = openStore(c)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.StartupRequireOllama {
+		hint := fmt.Sprintf("check OLLAMA_URL, pull model %q, or unset STARTUP_REQUIRE_OLLAMA to start with fallback summaries", c.OllamaModel)
+		err := retryStartup(c, "ollama", hint, func() error {
+			result := checkOllama(c)
+			if result.err != nil {
+				return result.err
+			}
+			if !result.OK {
+				return errors.New(result.Error)
+			}
+			return nil
+		})
+		if err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
+	log.Printf("startup: %s store ready", c.StoreBackend)
+	return store, nil
+}
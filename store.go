@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned by a StudentStore when no student matches
+var ErrNotFound = errors.New("student not found")
+
+// ErrStoreFull is returned by Create when the store has reached its size limit
+var ErrStoreFull = errors.New("student store is full")
+
+// StudentStore persists students. Implementations are safe for concurrent use.
+type StudentStore interface {
+	// Create stores a new student and returns it with its assigned ID, or
+	// ErrStoreFull if the store cannot hold any more
+	Create(ctx context.Context, s Student) (Student, error)
+	Get(ctx context.Context, id int) (Student, error)
+	// List returns all students ordered by ID
+	List(ctx context.Context) ([]Student, error)
+	// Update replaces the student with s.ID
+	Update(ctx context.Context, s Student) error
+	Delete(ctx context.Context, id int) error
+	// FindByEmail returns the lowest-ID student with the given email,
+	// compared case-insensitively, that is not soft-deleted
+	FindByEmail(ctx context.Context, email string) (Student, error)
+	// Ping reports whether the backend is reachable
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// sameStudent reports whether a and b hold the same values
+func sameStudent(a, b Student) bool {
+	if (a.DeletedAt == nil) != (b.DeletedAt == nil) {
+		return false
+	}
+	if a.DeletedAt != nil && !a.DeletedAt.Equal(*b.DeletedAt) {
+		return false
+	}
+	a.DeletedAt, b.DeletedAt = nil, nil
+	return a == b
+}
+
+// openStore opens the backend selected by STORE_BACKEND, shadowed by
+// SHADOW_BACKEND and behind the Redis cache when those are set
+func openStore(c Config) (StudentStore, error) {
+	store, err := openBackend(c.StoreBackend, c)
+	if err != nil {
+		return nil, err
+	}
+	if c.ShadowBackend != "" {
+		shadow, err := openBackend(c.ShadowBackend, c)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("open shadow store: %w", err)
+		}
+		store = &shadowStore{StudentStore: store, shadow: shadow, name: c.ShadowBackend}
+	}
+	if c.RedisURL == "" {
+		return store, nil
+	}
+	cached, err := newCachedStore(store, c)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	return cached, nil
+}
+
+// openBackend opens the named backend with its settings from c
+func openBackend(backend string, c Config) (StudentStore, error) {
+	switch backend {
+	case "memory":
+		if c.SnapshotInterval > 0 {
+			store, err := openSnapshotStore(c.SnapshotPath, c.MemoryMaxStudents, c.SnapshotInterval)
+			if err != nil {
+				return nil, err
+			}
+			if c.IDSequencePath != "" {
+				if err := store.useIDSequence(c.IDSequencePath); err != nil {
+					store.Close()
+					return nil, err
+				}
+			}
+			return store, nil
+		}
+		store := newMemoryStore(c.MemoryMaxStudents)
+		if c.IDSequencePath != "" {
+			if err := store.useIDSequence(c.IDSequencePath); err != nil {
+				return nil, err
+			}
+		}
+		return store, nil
+	case "file":
+		return openFileStore(c.FilePath, c.MemoryMaxStudents)
+	case "oplog":
+		return openOplogStore(c.OplogPath, c.MemoryMaxStudents, c.OplogCompactAfter)
+	case "bolt":
+		return openBoltStore(c.BoltPath)
+	case "sqlite":
+		return openSQLiteStore(c.SQLitePath)
+	case "postgres":
+		return openPostgresStore(c.DatabaseURL, c)
+	case "mysql":
+		return openMySQLStore(c.MySQLDSN, c)
+	case "mongodb":
+		return openMongoStore(c.MongoURI, c.MongoDatabase)
+	case "dynamodb":
+		return openDynamoStore(c)
+	case "etcd":
+		return openEtcdStore(c)
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", backend)
+	}
+}
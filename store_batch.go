@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// studentBatch is a set of writes applied as a single change by the bulk
+// endpoints
+type studentBatch struct {
+	// updates replace existing students; before holds their current values
+	// in the same order, for stores that have to undo a failed batch
+	updates []Student
+	before  []Student
+	// creates are new students, assigned IDs by the store
+	creates []Student
+}
+
+// batchStore is implemented by stores that apply a whole batch in one
+// transaction, so no reader ever sees part of it
+type batchStore interface {
+	// WriteBatch applies every write in b or none of them, and returns the
+	// created students with their IDs
+	WriteBatch(ctx context.Context, b studentBatch) ([]Student, error)
+}
+
+// batchLimitError refuses a batch too large for the store's transactions
+type batchLimitError struct {
+	backend string
+	limit   int
+}
+
+func (e *batchLimitError) Error() string {
+	return fmt.Sprintf("the %s store writes at most %d changes in one transaction", e.backend, e.limit)
+}
+
+// undoError reports a batch that failed part-way and could not be fully
+// undone, leaving the students in ids as the failed batch wrote them
+type undoError struct {
+	ids []int
+	err error
+}
+
+func (e *undoError) Error() string {
+	return fmt.Sprintf("batch failed and could not be undone for students %v: %v", e.ids, e.err)
+}
+
+func (e *undoError) Unwrap() error { return e.err }
+
+// writeBatch applies b to store in one transaction if the store supports
+// them, and otherwise write by write, undoing the writes already made if
+// one fails
+func writeBatch(ctx context.Context, store StudentStore, b studentBatch) ([]Student, error) {
+	if batcher, ok := store.(batchStore); ok {
+		return batcher.WriteBatch(ctx, b)
+	}
+	return compensateBatch(ctx, store, b)
+}
+
+// compensateBatch applies b write by write. If one fails, the creates
+// already made are deleted and the updates put back as they were before.
+// Readers can see a batch in progress, so this is only for stores without
+// transactions.
+func compensateBatch(ctx context.Context, store StudentStore, b studentBatch) ([]Student, error) {
+	var failed error
+	updated := 0
+	for _, student := range b.updates {
+		if err := store.Update(ctx, student); err != nil {
+			failed = fmt.Errorf("update student %d: %w", student.ID, err)
+			break
+		}
+		updated++
+	}
+	created := make([]Student, 0, len(b.creates))
+	if failed == nil {
+		for _, student := range b.creates {
+			student, err := store.Create(ctx, student)
+			if err != nil {
+				failed = err
+				break
+			}
+			created = append(created, student)
+		}
+	}
+	if failed == nil {
+		return created, nil
+	}
+
+	// Undo in reverse, on a context that is not cut short by the client
+	undo := context.WithoutCancel(ctx)
+	var stuck []int
+	for j := len(created) - 1; j >= 0; j-- {
+		if err := store.Delete(undo, created[j].ID); err != nil {
+			log.Printf("bulk: could not remove student %d after a failed batch: %v", created[j].ID, err)
+			stuck = append(stuck, created[j].ID)
+		}
+	}
+	for j := updated - 1; j >= 0; j-- {
+		if err := store.Update(undo, b.before[j]); err != nil {
+			log.Printf("bulk: could not restore student %d after a failed batch: %v", b.before[j].ID, err)
+			stuck = append(stuck, b.before[j].ID)
+		}
+	}
+	if len(stuck) > 0 {
+		return nil, &undoError{ids: stuck, err: failed}
+	}
+	return nil, failed
+}
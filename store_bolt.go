@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names. Students are keyed by their big-endian ID, so cursor
+// order is ID order. The email index maps lowercased email, a zero byte
+// and the ID to an empty value; emails may repeat under the allow policy.
+var (
+	boltStudentsBucket = []byte("students")
+	boltEmailBucket    = []byte("students_by_email")
+)
+
+// boltStore keeps students in an embedded bbolt database file
+type boltStore struct {
+	db *bolt.DB
+}
+
+// openBoltStore opens (creating if needed) the database at path
+func openBoltStore(path string) (*boltStore, error) {
+	// The timeout stops a second instance on the same file hanging forever
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltStudentsBucket, boltEmailBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create bolt buckets: %w", err)
+	}
+	return &boltStore{db: db}, nil
+}
+
+// boltKey encodes a student ID as a sortable key
+func boltKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+// emailPrefix is the email index prefix shared by all students with an email
+func emailPrefix(email string) []byte {
+	return append([]byte(strings.ToLower(email)), 0)
+}
+
+// emailKey is the email index entry for one student
+func emailKey(s Student) []byte {
+	return append(emailPrefix(s.Email), boltKey(s.ID)...)
+}
+
+// getStudent reads a student within a transaction
+func getStudent(tx *bolt.Tx, id int) (Student, error) {
+	raw := tx.Bucket(boltStudentsBucket).Get(boltKey(id))
+	if raw == nil {
+		return Student{}, ErrNotFound
+	}
+	var s Student
+	err := json.Unmarshal(raw, &s)
+	return s, err
+}
+
+// putStudent writes a student and its email index entry within a transaction
+func putStudent(tx *bolt.Tx, s Student) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltStudentsBucket).Put(boltKey(s.ID), raw); err != nil {
+		return err
+	}
+	return tx.Bucket(boltEmailBucket).Put(emailKey(s), nil)
+}
+
+// insertStudent assigns s the next ID and writes it within a transaction
+func insertStudent(tx *bolt.Tx, s Student) (Student, error) {
+	seq, err := tx.Bucket(boltStudentsBucket).NextSequence()
+	if err != nil {
+		return Student{}, err
+	}
+	s.ID = int(seq)
+	return s, putStudent(tx, s)
+}
+
+// replaceStudent replaces an existing student within a transaction
+func replaceStudent(tx *bolt.Tx, s Student) error {
+	old, err := getStudent(tx, s.ID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltEmailBucket).Delete(emailKey(old)); err != nil {
+		return err
+	}
+	return putStudent(tx, s)
+}
+
+func (b *boltStore) Create(_ context.Context, s Student) (Student, error) {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		s, err = insertStudent(tx, s)
+		return err
+	})
+	if err != nil {
+		return Student{}, err
+	}
+	return s, nil
+}
+
+// WriteBatch applies b in one bbolt transaction
+func (b *boltStore) WriteBatch(_ context.Context, batch studentBatch) ([]Student, error) {
+	var created []Student
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		for _, s := range batch.updates {
+			if err := replaceStudent(tx, s); err != nil {
+				return fmt.Errorf("update student %d: %w", s.ID, err)
+			}
+		}
+		created = make([]Student, 0, len(batch.creates))
+		for _, s := range batch.creates {
+			s, err := insertStudent(tx, s)
+			if err != nil {
+				return err
+			}
+			created = append(created, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (b *boltStore) Get(_ context.Context, id int) (Student, error) {
+	var s Student
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		s, err = getStudent(tx, id)
+		return err
+	})
+	return s, err
+}
+
+func (b *boltStore) List(_ context.Context) ([]Student, error) {
+	var list []Student
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltStudentsBucket).ForEach(func(_, raw []byte) error {
+			var s Student
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return err
+			}
+			list = append(list, s)
+			return nil
+		})
+	})
+	return list, err
+}
+
+func (b *boltStore) Update(_ context.Context, s Student) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return replaceStudent(tx, s)
+	})
+}
+
+func (b *boltStore) Delete(_ context.Context, id int) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		old, err := getStudent(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltEmailBucket).Delete(emailKey(old)); err != nil {
+			return err
+		}
+		return tx.Bucket(boltStudentsBucket).Delete(boltKey(id))
+	})
+}
+
+func (b *boltStore) FindByEmail(_ context.Context, email string) (Student, error) {
+	var s Student
+	err := b.db.View(func(tx *bolt.Tx) error {
+		prefix := emailPrefix(email)
+		// Index keys end in the big-endian ID, so matches come lowest first
+		cursor := tx.Bucket(boltEmailBucket).Cursor()
+		for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+			if len(key) != len(prefix)+8 {
+				continue
+			}
+			var err error
+			if s, err = getStudent(tx, int(binary.BigEndian.Uint64(key[len(prefix):]))); err != nil {
+				return err
+			}
+			if s.DeletedAt == nil {
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return Student{}, err
+	}
+	return s, nil
+}
+
+func (b *boltStore) Ping(_ context.Context) error {
+	return nil
+}
+
+func (b *boltStore) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys. All writes delete the list key, and the ID key of the student
+// they touch, so every replica sharing the Redis sees them.
+const (
+	cacheListKey     = "students:list"
+	cacheStudentKey  = "students:id:"
+	cacheCallTimeout = 200 * time.Millisecond
+)
+
+// cachedStore is a read-through Redis cache in front of another
+// StudentStore. Get and List are served from Redis when possible; writes go
+// to the store and then invalidate. While Redis is failing the cache is
+// bypassed, and it is used again once a health probe reaches it.
+type cachedStore struct {
+	StudentStore
+	redis *redis.Client
+	ttl   time.Duration
+
+	healthy atomic.Bool
+}
+
+// newCachedStore wraps store with the Redis at c.RedisURL. Redis being
+// unreachable is not an error: the cache starts bypassed.
+func newCachedStore(store StudentStore, c Config) (*cachedStore, error) {
+	opts, err := redis.ParseURL(c.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse REDIS_URL: %w", err)
+	}
+	cs := &cachedStore{StudentStore: store, redis: redis.NewClient(opts), ttl: c.CacheTTL}
+	if err := cs.pingCache(context.Background()); err != nil {
+		log.Printf("cache: redis unavailable, reading from the store: %v", err)
+	}
+	return cs, nil
+}
+
+// pingCache checks Redis and re-enables the cache if it answers
+func (cs *cachedStore) pingCache(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, cacheCallTimeout)
+	defer cancel()
+	err := cs.redis.Ping(ctx).Err()
+	cs.healthy.Store(err == nil)
+	return err
+}
+
+// failed bypasses the cache after a Redis error until the next good ping
+func (cs *cachedStore) failed(err error) {
+	if cs.healthy.Swap(false) {
+		log.Printf("cache: redis failed, reading from the store: %v", err)
+	}
+}
+
+// load reads a cached value into dst, reporting whether it was found
+func (cs *cachedStore) load(ctx context.Context, key string, dst any) bool {
+	if !cs.healthy.Load() {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, cacheCallTimeout)
+	defer cancel()
+
+	raw, err := cs.redis.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false
+	}
+	if err != nil {
+		cs.failed(err)
+		return false
+	}
+	return json.Unmarshal(raw, dst) == nil
+}
+
+// save caches a value read from the store
+func (cs *cachedStore) save(ctx context.Context, key string, value any) {
+	if !cs.healthy.Load() {
+		return
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, cacheCallTimeout)
+	defer cancel()
+	if err := cs.redis.Set(ctx, key, raw, cs.ttl).Err(); err != nil {
+		cs.failed(err)
+	}
+}
+
+// invalidate drops the cached list and the given student. It is attempted
+// even while the cache is bypassed, so a recovering Redis holds no entry
+// older than the TTL.
+func (cs *cachedStore) invalidate(ctx context.Context, id int) {
+	ctx, cancel := context.WithTimeout(ctx, cacheCallTimeout)
+	defer cancel()
+	if err := cs.redis.Del(ctx, cacheListKey, cacheStudentKey+strconv.Itoa(id)).Err(); err != nil {
+		cs.failed(err)
+	}
+}
+
+func (cs *cachedStore) Create(ctx context.Context, s Student) (Student, error) {
+	created, err := cs.StudentStore.Create(ctx, s)
+	if err == nil {
+		cs.invalidate(ctx, created.ID)
+	}
+	return created, err
+}
+
+func (cs *cachedStore) Get(ctx context.Context, id int) (Student, error) {
+	key := cacheStudentKey + strconv.Itoa(id)
+	var s Student
+	if cs.load(ctx, key, &s) {
+		return s, nil
+	}
+	s, err := cs.StudentStore.Get(ctx, id)
+	if err == nil {
+		cs.save(ctx, key, s)
+	}
+	return s, err
+}
+
+func (cs *cachedStore) List(ctx context.Context) ([]Student, error) {
+	var list []Student
+	if cs.load(ctx, cacheListKey, &list) {
+		return list, nil
+	}
+	list, err := cs.StudentStore.List(ctx)
+	if err == nil {
+		cs.save(ctx, cacheListKey, list)
+	}
+	return list, err
+}
+
+func (cs *cachedStore) Update(ctx context.Context, s Student) error {
+	err := cs.StudentStore.Update(ctx, s)
+	cs.invalidate(ctx, s.ID)
+	return err
+}
+
+func (cs *cachedStore) Delete(ctx context.Context, id int) error {
+	err := cs.StudentStore.Delete(ctx, id)
+	cs.invalidate(ctx, id)
+	return err
+}
+
+// WriteBatch applies b to the store and then invalidates every student it
+// touched, even if it failed part-way
+func (cs *cachedStore) WriteBatch(ctx context.Context, b studentBatch) ([]Student, error) {
+	created, err := writeBatch(ctx, cs.StudentStore, b)
+	for _, s := range b.updates {
+		cs.invalidate(ctx, s.ID)
+	}
+	for _, s := range created {
+		cs.invalidate(ctx, s.ID)
+	}
+	return created, err
+}
+
+func (cs *cachedStore) Close() error {
+	cs.redis.Close()
+	return cs.StudentStore.Close()
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// dynamoEmailIndex is the global secondary index used by FindByEmail. It is
+// keyed on the lowercased email and sorted by ID, so the first match is the
+// lowest ID.
+const dynamoEmailIndex = "email-index"
+
+// dynamoCounterID is the key of the item holding the ID sequence. Student
+// IDs start at 1, so it never collides with a student.
+const dynamoCounterID = 0
+
+// dynamoStudent is a student item
+type dynamoStudent struct {
+	ID         int        `dynamodbav:"id"`
+	Name       string     `dynamodbav:"name"`
+	Age        int        `dynamodbav:"age"`
+	Email      string     `dynamodbav:"email"`
+	EmailLower string     `dynamodbav:"email_lower"`
+	Version    int        `dynamodbav:"version"`
+	DeletedAt  *time.Time `dynamodbav:"deleted_at,omitempty"`
+}
+
+// dynamoStore keeps students in a DynamoDB table keyed by ID, with the ID
+// sequence held in a counter item in the same table
+type dynamoStore struct {
+	client *dynamodb.Client
+	table  string
+}
+
+// openDynamoStore connects to the table in c.DynamoTable, creating it with
+// on-demand billing if it does not exist. Credentials come from the usual
+// AWS sources: the environment, shared config or the instance role.
+func openDynamoStore(c Config) (*dynamoStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	d, err := newDynamoStore(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// newDynamoStore sets up the client for c.DynamoTable without checking the
+// table exists
+func newDynamoStore(ctx context.Context, c Config) (*dynamoStore, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if c.DynamoRegion != "" {
+		opts = append(opts, awsconfig.WithRegion(c.DynamoRegion))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	client := dynamodb.NewFromConfig(awsCfg, func(o *dynamodb.Options) {
+		if c.DynamoEndpoint != "" {
+			o.BaseEndpoint = aws.String(c.DynamoEndpoint)
+		}
+	})
+
+	return &dynamoStore{client: client, table: c.DynamoTable}, nil
+}
+
+// ensureTable creates the table and email index if the table is missing,
+// and waits for it to become active
+func (d *dynamoStore) ensureTable(ctx context.Context) error {
+	_, err := d.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(d.table)})
+	if err == nil {
+		return nil
+	}
+	var missing *types.ResourceNotFoundException
+	if !errors.As(err, &missing) {
+		return fmt.Errorf("describe dynamodb table %s: %w", d.table, err)
+	}
+
+	_, err = d.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(d.table),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeN},
+			{AttributeName: aws.String("email_lower"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName: aws.String(dynamoEmailIndex),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("email_lower"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("id"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+	})
+	var inUse *types.ResourceInUseException
+	if err != nil && !errors.As(err, &inUse) {
+		return fmt.Errorf("create dynamodb table %s: %w", d.table, err)
+	}
+	// Another replica may be creating it too; either way, wait for it
+	waiter := dynamodb.NewTableExistsWaiter(d.client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(d.table)}, time.Minute); err != nil {
+		return fmt.Errorf("wait for dynamodb table %s: %w", d.table, err)
+	}
+	return nil
+}
+
+// dynamoKey is the primary key of the item with id
+func dynamoKey(id int) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"id": &types.AttributeValueMemberN{Value: strconv.Itoa(id)}}
+}
+
+// marshalStudent converts s to an item
+func marshalStudent(s Student) (map[string]types.AttributeValue, error) {
+	return attributevalue.MarshalMap(dynamoStudent{
+		ID:         s.ID,
+		Name:       s.Name,
+		Age:        s.Age,
+		Email:      s.Email,
+		EmailLower: strings.ToLower(s.Email),
+		Version:    s.Version,
+		DeletedAt:  s.DeletedAt,
+	})
+}
+
+// unmarshalStudent converts an item to a student
+func unmarshalStudent(item map[string]types.AttributeValue) (Student, error) {
+	var doc dynamoStudent
+	if err := attributevalue.UnmarshalMap(item, &doc); err != nil {
+		return Student{}, err
+	}
+	return Student{ID: doc.ID, Name: doc.Name, Age: doc.Age, Email: doc.Email, Version: doc.Version, DeletedAt: doc.DeletedAt}, nil
+}
+
+// isConditionFailed reports whether err is a failed condition expression
+func isConditionFailed(err error) bool {
+	var failed *types.ConditionalCheckFailedException
+	return errors.As(err, &failed)
+}
+
+// nextID atomically increments and returns the student ID counter
+func (d *dynamoStore) nextID(ctx context.Context) (int, error) {
+	return d.reserveIDs(ctx, 1)
+}
+
+// reserveIDs atomically advances the student ID counter by n and returns
+// the last ID reserved
+func (d *dynamoStore) reserveIDs(ctx context.Context, n int) (int, error) {
+	out, err := d.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(d.table),
+		Key:                       dynamoKey(dynamoCounterID),
+		UpdateExpression:          aws.String("ADD seq :n"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":n": &types.AttributeValueMemberN{Value: strconv.Itoa(n)}},
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return 0, err
+	}
+	var counter struct {
+		Seq int `dynamodbav:"seq"`
+	}
+	err = attributevalue.UnmarshalMap(out.Attributes, &counter)
+	return counter.Seq, err
+}
+
+func (d *dynamoStore) Create(ctx context.Context, s Student) (Student, error) {
+	id, err := d.nextID(ctx)
+	if err != nil {
+		return Student{}, err
+	}
+	s.ID = id
+	item, err := marshalStudent(s)
+	if err != nil {
+		return Student{}, err
+	}
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(d.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		return Student{}, err
+	}
+	return s, nil
+}
+
+// dynamoMaxBatch is the most items DynamoDB accepts in one transaction
+const dynamoMaxBatch = 100
+
+// WriteBatch applies b with one TransactWriteItems call. The IDs for the
+// creates are reserved first, so a failed batch leaves a gap in them.
+func (d *dynamoStore) WriteBatch(ctx context.Context, b studentBatch) ([]Student, error) {
+	if len(b.updates)+len(b.creates) > dynamoMaxBatch {
+		return nil, &batchLimitError{backend: "dynamodb", limit: dynamoMaxBatch}
+	}
+
+	var items []types.TransactWriteItem
+	put := func(s Student, condition string) error {
+		item, err := marshalStudent(s)
+		if err != nil {
+			return err
+		}
+		items = append(items, types.TransactWriteItem{Put: &types.Put{
+			TableName:           aws.String(d.table),
+			Item:                item,
+			ConditionExpression: aws.String(condition),
+		}})
+		return nil
+	}
+	for _, s := range b.updates {
+		if s.ID == dynamoCounterID {
+			return nil, fmt.Errorf("update student %d: %w", s.ID, ErrNotFound)
+		}
+		if err := put(s, "attribute_exists(id)"); err != nil {
+			return nil, err
+		}
+	}
+	created := make([]Student, 0, len(b.creates))
+	if len(b.creates) > 0 {
+		last, err := d.reserveIDs(ctx, len(b.creates))
+		if err != nil {
+			return nil, err
+		}
+		for i, s := range b.creates {
+			s.ID = last - len(b.creates) + 1 + i
+			if err := put(s, "attribute_not_exists(id)"); err != nil {
+				return nil, err
+			}
+			created = append(created, s)
+		}
+	}
+	if len(items) == 0 {
+		return created, nil
+	}
+
+	_, err := d.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		for i, reason := range canceled.CancellationReasons {
+			if i < len(b.updates) && aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return nil, fmt.Errorf("update student %d: %w", b.updates[i].ID, ErrNotFound)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (d *dynamoStore) Get(ctx context.Context, id int) (Student, error) {
+	if id == dynamoCounterID {
+		return Student{}, ErrNotFound
+	}
+	out, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(d.table),
+		Key:            dynamoKey(id),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return Student{}, err
+	}
+	if out.Item == nil {
+		return Student{}, ErrNotFound
+	}
+	return unmarshalStudent(out.Item)
+}
+
+// List scans the whole table. DynamoDB returns items in hash order, so they
+// are sorted afterwards.
+func (d *dynamoStore) List(ctx context.Context) ([]Student, error) {
+	var list []Student
+	paginator := dynamodb.NewScanPaginator(d.client, &dynamodb.ScanInput{
+		TableName:      aws.String(d.table),
+		ConsistentRead: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			s, err := unmarshalStudent(item)
+			if err != nil {
+				return nil, err
+			}
+			if s.ID != dynamoCounterID {
+				list = append(list, s)
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (d *dynamoStore) Update(ctx context.Context, s Student) error {
+	if s.ID == dynamoCounterID {
+		return ErrNotFound
+	}
+	item, err := marshalStudent(s)
+	if err != nil {
+		return err
+	}
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(d.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if isConditionFailed(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (d *dynamoStore) Delete(ctx context.Context, id int) error {
+	if id == dynamoCounterID {
+		return ErrNotFound
+	}
+	_, err := d.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(d.table),
+		Key:                 dynamoKey(id),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if isConditionFailed(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// FindByEmail queries the email index. Global secondary indexes are
+// eventually consistent, so a student created moments ago may not be found.
+func (d *dynamoStore) FindByEmail(ctx context.Context, email string) (Student, error) {
+	// The filter applies after each page is read, so a page can come back
+	// empty while later ones still match
+	paginator := dynamodb.NewQueryPaginator(d.client, &dynamodb.QueryInput{
+		TableName:                 aws.String(d.table),
+		IndexName:                 aws.String(dynamoEmailIndex),
+		KeyConditionExpression:    aws.String("email_lower = :email"),
+		FilterExpression:          aws.String("attribute_not_exists(deleted_at)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":email": &types.AttributeValueMemberS{Value: strings.ToLower(email)}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return Student{}, err
+		}
+		if len(page.Items) > 0 {
+			return unmarshalStudent(page.Items[0])
+		}
+	}
+	return Student{}, ErrNotFound
+}
+
+func (d *dynamoStore) Ping(ctx context.Context) error {
+	_, err := d.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(d.table)})
+	return err
+}
+
+func (d *dynamoStore) Close() error {
+	return nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// etcdStore keeps students in etcd, so every replica reads and writes the
+// same data. Under the configured prefix it stores:
+//
+//	students/<id>        the student as JSON
+//	email/<email>/<id>   an empty key per student, for FindByEmail
+//	next_id              the next ID to assign
+//
+// IDs in keys are zero-padded so key order is ID order, and emails are
+// lowercased and escaped. Each change is a transaction conditioned on the
+// revisions it read, retried if another replica changed them first.
+type etcdStore struct {
+	client *clientv3.Client
+	prefix string
+}
+
+// etcdDialTimeout bounds connecting to etcd on startup
+const etcdDialTimeout = 5 * time.Second
+
+// openEtcdStore connects to the comma-separated etcd endpoints
+func openEtcdStore(c Config) (*etcdStore, error) {
+	var endpoints []string
+	for _, endpoint := range strings.Split(c.EtcdEndpoints, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: etcdDialTimeout,
+		Username:    c.EtcdUsername,
+		Password:    c.EtcdPassword,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to etcd: %w", err)
+	}
+
+	e := &etcdStore{client: client, prefix: c.EtcdPrefix}
+	ctx, cancel := context.WithTimeout(context.Background(), etcdDialTimeout)
+	defer cancel()
+	if err := e.Ping(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to etcd: %w", err)
+	}
+	return e, nil
+}
+
+func (e *etcdStore) studentKey(id int) string {
+	return fmt.Sprintf("%sstudents/%020d", e.prefix, id)
+}
+
+func (e *etcdStore) emailPrefix(email string) string {
+	return e.prefix + "email/" + url.PathEscape(strings.ToLower(email)) + "/"
+}
+
+func (e *etcdStore) emailKey(s Student) string {
+	return fmt.Sprintf("%s%020d", e.emailPrefix(s.Email), s.ID)
+}
+
+func (e *etcdStore) counterKey() string {
+	return e.prefix + "next_id"
+}
+
+// load reads the student with id and the revision it was last changed at
+func (e *etcdStore) load(ctx context.Context, id int) (Student, int64, error) {
+	resp, err := e.client.Get(ctx, e.studentKey(id))
+	if err != nil {
+		return Student{}, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return Student{}, 0, ErrNotFound
+	}
+	var s Student
+	if err := json.Unmarshal(resp.Kvs[0].Value, &s); err != nil {
+		return Student{}, 0, fmt.Errorf("decode student %d: %w", id, err)
+	}
+	return s, resp.Kvs[0].ModRevision, nil
+}
+
+func (e *etcdStore) Create(ctx context.Context, s Student) (Student, error) {
+	for {
+		resp, err := e.client.Get(ctx, e.counterKey())
+		if err != nil {
+			return Student{}, err
+		}
+		// A missing counter has revision 0, which the comparison matches
+		id, rev := 1, int64(0)
+		if len(resp.Kvs) > 0 {
+			if id, err = strconv.Atoi(string(resp.Kvs[0].Value)); err != nil {
+				return Student{}, fmt.Errorf("decode etcd ID counter: %w", err)
+			}
+			rev = resp.Kvs[0].ModRevision
+		}
+
+		s.ID = id
+		value, err := json.Marshal(s)
+		if err != nil {
+			return Student{}, err
+		}
+		txn, err := e.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(e.counterKey()), "=", rev)).
+			Then(
+				clientv3.OpPut(e.counterKey(), strconv.Itoa(id+1)),
+				clientv3.OpPut(e.studentKey(id), string(value)),
+				clientv3.OpPut(e.emailKey(s), ""),
+			).
+			Commit()
+		if err != nil {
+			return Student{}, err
+		}
+		if txn.Succeeded {
+			return s, nil
+		}
+	}
+}
+
+// etcdMaxBatch is the most students one batch may write. A student takes
+// up to three operations and the ID counter one, and an etcd server
+// accepts 128 operations per transaction unless --max-txn-ops says more.
+const etcdMaxBatch = 42
+
+// WriteBatch applies b in one etcd transaction, retried if another writer
+// changes one of its students or the ID counter first
+func (e *etcdStore) WriteBatch(ctx context.Context, b studentBatch) ([]Student, error) {
+	if len(b.updates)+len(b.creates) > etcdMaxBatch {
+		return nil, &batchLimitError{backend: "etcd", limit: etcdMaxBatch}
+	}
+	for {
+		var cmps []clientv3.Cmp
+		var ops []clientv3.Op
+		for _, s := range b.updates {
+			old, rev, err := e.load(ctx, s.ID)
+			if err != nil {
+				return nil, fmt.Errorf("update student %d: %w", s.ID, err)
+			}
+			value, err := json.Marshal(s)
+			if err != nil {
+				return nil, err
+			}
+			cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(e.studentKey(s.ID)), "=", rev))
+			ops = append(ops, clientv3.OpPut(e.studentKey(s.ID), string(value)))
+			if e.emailKey(old) != e.emailKey(s) {
+				ops = append(ops, clientv3.OpDelete(e.emailKey(old)), clientv3.OpPut(e.emailKey(s), ""))
+			}
+		}
+
+		created := make([]Student, 0, len(b.creates))
+		if len(b.creates) > 0 {
+			resp, err := e.client.Get(ctx, e.counterKey())
+			if err != nil {
+				return nil, err
+			}
+			id, rev := 1, int64(0)
+			if len(resp.Kvs) > 0 {
+				if id, err = strconv.Atoi(string(resp.Kvs[0].Value)); err != nil {
+					return nil, fmt.Errorf("decode etcd ID counter: %w", err)
+				}
+				rev = resp.Kvs[0].ModRevision
+			}
+			cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(e.counterKey()), "=", rev))
+			for _, s := range b.creates {
+				s.ID = id
+				id++
+				value, err := json.Marshal(s)
+				if err != nil {
+					return nil, err
+				}
+				ops = append(ops, clientv3.OpPut(e.studentKey(s.ID), string(value)), clientv3.OpPut(e.emailKey(s), ""))
+				created = append(created, s)
+			}
+			ops = append(ops, clientv3.OpPut(e.counterKey(), strconv.Itoa(id)))
+		}
+
+		txn, err := e.client.Txn(ctx).If(cmps...).Then(ops...).Commit()
+		if err != nil {
+			return nil, err
+		}
+		if txn.Succeeded {
+			return created, nil
+		}
+	}
+}
+
+func (e *etcdStore) Get(ctx context.Context, id int) (Student, error) {
+	s, _, err := e.load(ctx, id)
+	return s, err
+}
+
+func (e *etcdStore) List(ctx context.Context) ([]Student, error) {
+	resp, err := e.client.Get(ctx, e.prefix+"students/", clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Student, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var s Student
+		if err := json.Unmarshal(kv.Value, &s); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", kv.Key, err)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+func (e *etcdStore) Update(ctx context.Context, s Student) error {
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	for {
+		old, rev, err := e.load(ctx, s.ID)
+		if err != nil {
+			return err
+		}
+		ops := []clientv3.Op{clientv3.OpPut(e.studentKey(s.ID), string(value))}
+		// A transaction may not touch a key twice, so the index entry is
+		// only moved when the email changes
+		if e.emailKey(old) != e.emailKey(s) {
+			ops = append(ops, clientv3.OpDelete(e.emailKey(old)), clientv3.OpPut(e.emailKey(s), ""))
+		}
+		txn, err := e.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(e.studentKey(s.ID)), "=", rev)).
+			Then(ops...).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if txn.Succeeded {
+			return nil
+		}
+	}
+}
+
+func (e *etcdStore) Delete(ctx context.Context, id int) error {
+	for {
+		old, rev, err := e.load(ctx, id)
+		if err != nil {
+			return err
+		}
+		txn, err := e.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(e.studentKey(id)), "=", rev)).
+			Then(
+				clientv3.OpDelete(e.emailKey(old)),
+				clientv3.OpDelete(e.studentKey(id)),
+			).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if txn.Succeeded {
+			return nil
+		}
+	}
+}
+
+func (e *etcdStore) FindByEmail(ctx context.Context, email string) (Student, error) {
+	resp, err := e.client.Get(ctx, e.emailPrefix(email),
+		clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend), clientv3.WithKeysOnly())
+	if err != nil {
+		return Student{}, err
+	}
+	// Index keys are in ID order; skip students that are soft-deleted, or
+	// were deleted since the index was read
+	for _, kv := range resp.Kvs {
+		key := string(kv.Key)
+		id, err := strconv.Atoi(key[strings.LastIndex(key, "/")+1:])
+		if err != nil {
+			return Student{}, fmt.Errorf("decode %s: %w", key, err)
+		}
+		s, err := e.Get(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return Student{}, err
+		}
+		if s.DeletedAt == nil {
+			return s, nil
+		}
+	}
+	return Student{}, ErrNotFound
+}
+
+func (e *etcdStore) Ping(ctx context.Context) error {
+	_, err := e.client.Get(ctx, e.counterKey(), clientv3.WithCountOnly())
+	return err
+}
+
+func (e *etcdStore) Close() error {
+	return e.client.Close()
+}
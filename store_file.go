@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileSnapshot is the on-disk form of a fileStore
+type fileSnapshot struct {
+	NextID   int       `json:"next_id"`
+	Students []Student `json:"students"`
+}
+
+// fileStore is a memoryStore that rewrites a JSON file after every change.
+// Each write goes to a temporary file that is then renamed over the old
+// one, so a crash leaves either the previous or the new list, never a torn
+// file.
+type fileStore struct {
+	*memoryStore
+	path string
+	lock *os.File
+
+	// mu serializes changes so the file is written in the order they happen
+	mu sync.Mutex
+}
+
+// openFileStore loads the students saved at path, starting empty if the
+// file does not exist yet
+func openFileStore(path string, limit int) (*fileStore, error) {
+	lock, err := lockDataFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &fileStore{memoryStore: newMemoryStore(limit), path: path, lock: lock}
+	snap, err := readSnapshotFile(path)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	f.restore(snap)
+	return f, nil
+}
+
+// readSnapshotFile reads the students saved at path, or none if the file
+// does not exist
+func readSnapshotFile(path string) (fileSnapshot, error) {
+	snap := fileSnapshot{NextID: 1}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return snap, nil
+	}
+	if err != nil {
+		return snap, fmt.Errorf("read student file: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, &snap); err != nil {
+		return snap, fmt.Errorf("parse student file %s: %w", path, err)
+	}
+	// Guard against a hand-edited file whose next_id would reuse an ID
+	for _, s := range snap.Students {
+		snap.NextID = max(snap.NextID, s.ID+1)
+	}
+	return snap, nil
+}
+
+// save writes snap to the file atomically
+func (f *fileStore) save(snap fileSnapshot) error {
+	return writeSnapshotFile(f.path, snap)
+}
+
+// writeSnapshotFile writes snap to path atomically
+func writeSnapshotFile(path string, snap fileSnapshot) error {
+	raw, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, raw); err != nil {
+		return fmt.Errorf("write student file: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path with raw, so that a crash
+// leaves either the old or the new contents
+func writeFileAtomic(path string, raw []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	// Flush before the rename so the new name never points at missing data
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// change applies fn to the in-memory students and saves the result. If
+// saving fails the change is undone, so memory never runs ahead of disk.
+func (f *fileStore) change(fn func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before := f.snapshot()
+	if err := fn(); err != nil {
+		return err
+	}
+	if err := f.save(f.snapshot()); err != nil {
+		f.restore(before)
+		return err
+	}
+	return nil
+}
+
+func (f *fileStore) Create(ctx context.Context, s Student) (Student, error) {
+	var created Student
+	err := f.change(func() error {
+		var err error
+		created, err = f.memoryStore.Create(ctx, s)
+		return err
+	})
+	return created, err
+}
+
+func (f *fileStore) Update(ctx context.Context, s Student) error {
+	return f.change(func() error { return f.memoryStore.Update(ctx, s) })
+}
+
+func (f *fileStore) Delete(ctx context.Context, id int) error {
+	return f.change(func() error { return f.memoryStore.Delete(ctx, id) })
+}
+
+// Close releases the lock on the file
+func (f *fileStore) Close() error {
+	return f.lock.Close()
+}
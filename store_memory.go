@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// memoryStore keeps students in process memory; they are lost on restart.
+// It holds at most limit students so a flood of creates cannot exhaust the
+// process's memory.
+type memoryStore struct {
+	mu       sync.RWMutex
+	students []Student
+	nextID   int
+	limit    int
+	// sequence, if set, keeps IDs from being reused across restarts
+	sequence *idSequence
+}
+
+func newMemoryStore(limit int) *memoryStore {
+	return &memoryStore{nextID: 1, limit: limit}
+}
+
+// useIDSequence continues the IDs after the ones recorded in the sequence
+// file at path, and records new ones there from now on
+func (m *memoryStore) useIDSequence(path string) error {
+	sequence, err := openIDSequence(path)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID = max(m.nextID, sequence.reserved)
+	m.sequence = sequence
+	return nil
+}
+
+// index returns the position of the student with the given ID, or -1.
+// The caller must hold m.mu.
+func (m *memoryStore) index(id int) int {
+	for i, student := range m.students {
+		if student.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// snapshot copies the students and the next ID to assign
+func (m *memoryStore) snapshot() fileSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return fileSnapshot{NextID: m.nextID, Students: append([]Student{}, m.students...)}
+}
+
+// restore replaces the contents with a snapshot
+func (m *memoryStore) restore(snap fileSnapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.students = append([]Student(nil), snap.Students...)
+	m.nextID = max(snap.NextID, 1)
+	memoryStoreStudents.Set(int64(len(m.students)))
+}
+
+// capacity reports how many students are held, soft-deleted ones included,
+// and the limit
+func (m *memoryStore) capacity() (used, limit int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.students), m.limit
+}
+
+func (m *memoryStore) Create(_ context.Context, s Student) (Student, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.students) >= m.limit {
+		memoryStoreRejected.Add(1)
+		return Student{}, ErrStoreFull
+	}
+
+	if m.sequence != nil {
+		if err := m.sequence.reserve(m.nextID); err != nil {
+			return Student{}, err
+		}
+	}
+	s.ID = m.nextID
+	m.nextID++
+	m.students = append(m.students, s)
+	memoryStoreStudents.Set(int64(len(m.students)))
+	return s, nil
+}
+
+func (m *memoryStore) Get(_ context.Context, id int) (Student, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if i := m.index(id); i >= 0 {
+		return m.students[i], nil
+	}
+	return Student{}, ErrNotFound
+}
+
+func (m *memoryStore) List(_ context.Context) ([]Student, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.students == nil {
+		return nil, nil
+	}
+	return append([]Student(nil), m.students...), nil
+}
+
+func (m *memoryStore) Update(_ context.Context, s Student) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.index(s.ID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.students[i] = s
+	return nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.students = append(m.students[:i], m.students[i+1:]...)
+	memoryStoreStudents.Set(int64(len(m.students)))
+	return nil
+}
+
+func (m *memoryStore) FindByEmail(_ context.Context, email string) (Student, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, student := range m.students {
+		if strings.EqualFold(student.Email, email) && student.DeletedAt == nil {
+			return student, nil
+		}
+	}
+	return Student{}, ErrNotFound
+}
+
+func (m *memoryStore) Ping(_ context.Context) error {
+	return nil
+}
+
+func (m *memoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// emailCollation compares emails case-insensitively. The email index is
+// built with it, and FindByEmail must use the same collation to hit it.
+var emailCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// mongoStudent is a student document. The integer id is kept separate from
+// Mongo's _id so IDs stay the same across backends.
+type mongoStudent struct {
+	ID      int    `bson:"id"`
+	Name    string `bson:"name"`
+	Age     int    `bson:"age"`
+	Email   string `bson:"email"`
+	Version int    `bson:"version"`
+	// DeletedAt is stored with millisecond precision
+	DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+}
+
+// mongoStore keeps students in a MongoDB collection. IDs are allocated from
+// a counter document in a second collection.
+type mongoStore struct {
+	client   *mongo.Client
+	students *mongo.Collection
+	counters *mongo.Collection
+}
+
+// openMongoStore connects to uri and ensures the indexes on the students
+// collection in database
+func openMongoStore(uri, database string) (*mongoStore, error) {
+	client, err := connectMongo(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	db := client.Database(database)
+	m := &mongoStore{client: client, students: db.Collection("students"), counters: db.Collection("counters")}
+	_, err = m.students.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetCollation(emailCollation)},
+	})
+	if err != nil {
+		client.Disconnect(ctx)
+		return nil, fmt.Errorf("create mongodb indexes: %w", err)
+	}
+	return m, nil
+}
+
+// connectMongo connects to uri and pings it
+func connectMongo(uri string) (*mongo.Client, error) {
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("open mongodb client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(ctx)
+		return nil, fmt.Errorf("connect to mongodb: %w", err)
+	}
+	return client, nil
+}
+
+// nextID atomically increments and returns the student ID counter
+func (m *mongoStore) nextID(ctx context.Context) (int, error) {
+	return m.reserveIDs(ctx, 1)
+}
+
+// reserveIDs atomically advances the student ID counter by n and returns
+// the last ID reserved
+func (m *mongoStore) reserveIDs(ctx context.Context, n int) (int, error) {
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	err := m.counters.FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: "students"}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: n}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	return counter.Seq, err
+}
+
+// decodeStudent reads one student document
+func decodeStudent(res *mongo.SingleResult) (Student, error) {
+	var doc mongoStudent
+	err := res.Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Student{}, ErrNotFound
+	}
+	return Student(doc), err
+}
+
+func (m *mongoStore) Create(ctx context.Context, s Student) (Student, error) {
+	id, err := m.nextID(ctx)
+	if err != nil {
+		return Student{}, err
+	}
+	s.ID = id
+	if _, err := m.students.InsertOne(ctx, mongoStudent(s)); err != nil {
+		return Student{}, err
+	}
+	return s, nil
+}
+
+// mongoIllegalOperation is the error code a standalone server, which has
+// no transactions, answers them with
+const mongoIllegalOperation = 20
+
+// WriteBatch applies b in one multi-document transaction. Those need a
+// replica set or sharded cluster; against a standalone server the batch is
+// applied write by write instead. The IDs for the creates are reserved
+// first, so a failed batch leaves a gap in them.
+func (m *mongoStore) WriteBatch(ctx context.Context, b studentBatch) ([]Student, error) {
+	created := make([]Student, 0, len(b.creates))
+	if len(b.creates) > 0 {
+		last, err := m.reserveIDs(ctx, len(b.creates))
+		if err != nil {
+			return nil, err
+		}
+		for i, s := range b.creates {
+			s.ID = last - len(b.creates) + 1 + i
+			created = append(created, s)
+		}
+	}
+
+	session, err := m.client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(ctx context.Context) (any, error) {
+		for _, s := range b.updates {
+			res, err := m.students.ReplaceOne(ctx, bson.D{{Key: "id", Value: s.ID}}, mongoStudent(s))
+			if err != nil {
+				return nil, err
+			}
+			if res.MatchedCount == 0 {
+				return nil, fmt.Errorf("update student %d: %w", s.ID, ErrNotFound)
+			}
+		}
+		for _, s := range created {
+			if _, err := m.students.InsertOne(ctx, mongoStudent(s)); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(mongoIllegalOperation) {
+		return compensateBatch(ctx, m, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (m *mongoStore) Get(ctx context.Context, id int) (Student, error) {
+	return decodeStudent(m.students.FindOne(ctx, bson.D{{Key: "id", Value: id}}))
+}
+
+func (m *mongoStore) List(ctx context.Context) ([]Student, error) {
+	cursor, err := m.students.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var list []Student
+	for cursor.Next(ctx) {
+		var doc mongoStudent
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		list = append(list, Student(doc))
+	}
+	return list, cursor.Err()
+}
+
+func (m *mongoStore) Update(ctx context.Context, s Student) error {
+	res, err := m.students.ReplaceOne(ctx, bson.D{{Key: "id", Value: s.ID}}, mongoStudent(s))
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoStore) Delete(ctx context.Context, id int) error {
+	res, err := m.students.DeleteOne(ctx, bson.D{{Key: "id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoStore) FindByEmail(ctx context.Context, email string) (Student, error) {
+	opts := options.FindOne().SetCollation(emailCollation).SetSort(bson.D{{Key: "id", Value: 1}})
+	// A null match also finds documents without the field
+	filter := bson.D{{Key: "email", Value: email}, {Key: "deleted_at", Value: nil}}
+	return decodeStudent(m.students.FindOne(ctx, filter, opts))
+}
+
+func (m *mongoStore) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx, nil)
+}
+
+func (m *mongoStore) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return m.client.Disconnect(ctx)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// The email column has a case-insensitive collation, so plain equality
+// matches emails the way the other backends do
+var mysqlDialect = sqlDialect{
+	name: "mysql",
+	createMigrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INT          NOT NULL PRIMARY KEY,
+		name       VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	recordMigration:   `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`,
+	lockMigrations:    `SELECT GET_LOCK('students_schema_migrations', 60)`,
+	unlockMigrations:  `SELECT RELEASE_LOCK('students_schema_migrations')`,
+	lockReturnsResult: true,
+	repeatedDDL:       isMySQLRepeatedDDL,
+	insert:            `INSERT INTO students (name, age, email, version, deleted_at) VALUES (?, ?, ?, ?, ?)`,
+	lastInsertID:      true,
+	get:               `SELECT id, name, age, email, version, deleted_at FROM students WHERE id = ?`,
+	list:              `SELECT id, name, age, email, version, deleted_at FROM students ORDER BY id`,
+	update:            `UPDATE students SET name = ?, age = ?, email = ?, version = ?, deleted_at = ? WHERE id = ?`,
+	delete:            `DELETE FROM students WHERE id = ?`,
+	findByEmail:       `SELECT id, name, age, email, version, deleted_at FROM students WHERE email = ? AND deleted_at IS NULL ORDER BY id LIMIT 1`,
+}
+
+// openMySQLStore connects to the MySQL or MariaDB database at dsn with the
+// pool limits from c and applies pending migrations
+func openMySQLStore(dsn string, c Config) (*sqlStore, error) {
+	db, err := connectMySQL(dsn, c)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	q, err := newSQLStore(ctx, db, mysqlDialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// connectMySQL opens and pings the database at dsn, without touching its
+// schema
+func connectMySQL(dsn string, c Config) (*sql.DB, error) {
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse MYSQL_DSN: %w", err)
+	}
+	// MySQL otherwise reports an update that changes nothing as affecting
+	// no rows, which the store would read as a missing student
+	mc.ClientFoundRows = true
+	// Scan DATETIME columns into time.Time, read and written as UTC
+	mc.ParseTime = true
+	mc.Loc = time.UTC
+
+	connector, err := mysql.NewConnector(mc)
+	if err != nil {
+		return nil, fmt.Errorf("open mysql database: %w", err)
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(c.DBMaxOpenConns)
+	db.SetMaxIdleConns(c.DBMaxIdleConns)
+	db.SetConnMaxLifetime(c.DBConnMaxLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect to mysql: %w", err)
+	}
+	return db, nil
+}
+
+// isMySQLRepeatedDDL reports whether err is MySQL refusing to add a column
+// or index that already exists. MySQL, unlike MariaDB, has no
+// ADD COLUMN IF NOT EXISTS.
+func isMySQLRepeatedDDL(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	// ER_DUP_FIELDNAME and ER_DUP_KEYNAME
+	return errors.As(err, &mysqlErr) && (mysqlErr.Number == 1060 || mysqlErr.Number == 1061)
+}
+
+// redactMySQLDSN hides the password in a MySQL DSN
+func redactMySQLDSN(dsn string) string {
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil || dsn == "" {
+		return redact(dsn)
+	}
+	if mc.Passwd != "" {
+		mc.Passwd = "xxxxx"
+	}
+	return mc.FormatDSN()
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Operation log record types
+const (
+	oplogSnapshot = "snapshot"
+	oplogCreate   = "create"
+	oplogUpdate   = "update"
+	oplogDelete   = "delete"
+)
+
+// oplogRecord is one line of the operation log
+type oplogRecord struct {
+	Op      string   `json:"op"`
+	Student *Student `json:"student,omitempty"`
+	ID      int      `json:"id,omitempty"`
+	// Snapshot records only
+	NextID   int       `json:"next_id,omitempty"`
+	Students []Student `json:"students,omitempty"`
+}
+
+// oplogStore is a memoryStore whose changes are appended to a log on disk
+// before they are applied, so the students can be rebuilt after a crash by
+// replaying it. Once compactAfter records have been appended, the log is
+// rewritten as a single snapshot record.
+type oplogStore struct {
+	*memoryStore
+	path         string
+	compactAfter int
+	lock         *os.File
+
+	// mu serializes changes so records are appended in the order applied
+	mu       sync.Mutex
+	file     *os.File
+	appended int
+	// failed is set when a torn record could not be cut from the log; no
+	// more records are appended after it, so it stays the last line, which
+	// replay ignores
+	failed error
+}
+
+// openOplogStore replays the log at path, if any, and compacts it
+func openOplogStore(path string, limit, compactAfter int) (*oplogStore, error) {
+	lock, err := lockDataFile(path)
+	if err != nil {
+		return nil, err
+	}
+	o := &oplogStore{memoryStore: newMemoryStore(limit), path: path, compactAfter: compactAfter, lock: lock}
+
+	snap, n, err := replayOplog(path)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	o.restore(snap)
+	if n > 0 {
+		log.Printf("oplog: recovered %d student(s) from %d record(s) in %s", len(snap.Students), n, path)
+	}
+
+	// Start every run from a compact log, which also drops a torn last line
+	if err := o.compact(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return o, nil
+}
+
+// replayOplog rebuilds the students from the log at path and returns them
+// with the number of records read. A last line cut short by a crash is
+// ignored; a bad line anywhere else is an error.
+func replayOplog(path string) (fileSnapshot, int, error) {
+	snap := fileSnapshot{NextID: 1}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return snap, 0, nil
+	}
+	if err != nil {
+		return snap, 0, fmt.Errorf("open operation log: %w", err)
+	}
+	defer file.Close()
+
+	index := func(id int) int {
+		for i, s := range snap.Students {
+			if s.ID == id {
+				return i
+			}
+		}
+		return -1
+	}
+
+	reader := bufio.NewReader(file)
+	n := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				log.Printf("oplog: ignoring incomplete last record in %s", path)
+			}
+			return snap, n, nil
+		}
+		if err != nil {
+			return snap, n, fmt.Errorf("read operation log: %w", err)
+		}
+		n++
+
+		var rec oplogRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return snap, n, fmt.Errorf("operation log %s record %d: %w", path, n, err)
+		}
+		switch rec.Op {
+		case oplogSnapshot:
+			snap = fileSnapshot{NextID: rec.NextID, Students: rec.Students}
+		case oplogCreate:
+			snap.Students = append(snap.Students, *rec.Student)
+			snap.NextID = max(snap.NextID, rec.Student.ID+1)
+		case oplogUpdate:
+			if i := index(rec.Student.ID); i >= 0 {
+				snap.Students[i] = *rec.Student
+			}
+		case oplogDelete:
+			if i := index(rec.ID); i >= 0 {
+				snap.Students = append(snap.Students[:i], snap.Students[i+1:]...)
+			}
+		default:
+			return snap, n, fmt.Errorf("operation log %s record %d: unknown op %q", path, n, rec.Op)
+		}
+	}
+}
+
+// appendRecord writes one record and syncs it to disk. The caller must hold o.mu.
+func (o *oplogStore) appendRecord(rec oplogRecord) error {
+	if o.failed != nil {
+		return o.failed
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	offset, err := o.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("append to operation log: %w", err)
+	}
+	_, err = o.file.Write(append(line, '\n'))
+	if err == nil {
+		err = o.file.Sync()
+	}
+	if err != nil {
+		// Cut the log back to the last whole record, since replay rejects
+		// a torn line anywhere but at the end
+		if terr := o.file.Truncate(offset); terr != nil {
+			o.failed = fmt.Errorf("operation log %s may end in a partial record, so no more changes are accepted until restart: %w", o.path, terr)
+			log.Printf("oplog: %v", o.failed)
+		}
+		return fmt.Errorf("append to operation log: %w", err)
+	}
+
+	o.appended++
+	return nil
+}
+
+// maybeCompact compacts the log once enough records have been appended. It
+// runs after a change is applied, so the snapshot includes it. The record
+// is already durable, so a failed compaction only leaves the log longer
+// than needed. The caller must hold o.mu.
+func (o *oplogStore) maybeCompact() {
+	if o.appended < o.compactAfter {
+		return
+	}
+	if err := o.compact(); err != nil {
+		log.Printf("oplog: compaction failed: %v", err)
+	}
+}
+
+// compact replaces the log with a single snapshot record, written to a
+// temporary file and renamed into place. The caller must hold o.mu, or be
+// opening the store.
+func (o *oplogStore) compact() error {
+	snap := o.snapshot()
+	line, err := json.Marshal(oplogRecord{Op: oplogSnapshot, NextID: snap.NextID, Students: snap.Students})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(o.path), "."+filepath.Base(o.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("compact operation log: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(line, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact operation log: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("compact operation log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("compact operation log: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), o.path); err != nil {
+		return fmt.Errorf("compact operation log: %w", err)
+	}
+
+	file, err := os.OpenFile(o.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open operation log: %w", err)
+	}
+	if o.file != nil {
+		o.file.Close()
+	}
+	o.file = file
+	o.appended = 0
+	return nil
+}
+
+// Each change is checked against the in-memory state, logged, and only
+// then applied, so a change that fails to log is never visible
+
+func (o *oplogStore) Create(ctx context.Context, s Student) (Student, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.memoryStore.mu.RLock()
+	s.ID = o.nextID
+	full := len(o.students) >= o.limit
+	o.memoryStore.mu.RUnlock()
+	if full {
+		memoryStoreRejected.Add(1)
+		return Student{}, ErrStoreFull
+	}
+
+	if err := o.appendRecord(oplogRecord{Op: oplogCreate, Student: &s}); err != nil {
+		return Student{}, err
+	}
+	// Changes are serialized by o.mu, so the store assigns the logged ID
+	created, err := o.memoryStore.Create(ctx, s)
+	o.maybeCompact()
+	return created, err
+}
+
+func (o *oplogStore) Update(ctx context.Context, s Student) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, err := o.memoryStore.Get(ctx, s.ID); err != nil {
+		return err
+	}
+	if err := o.appendRecord(oplogRecord{Op: oplogUpdate, Student: &s}); err != nil {
+		return err
+	}
+	err := o.memoryStore.Update(ctx, s)
+	o.maybeCompact()
+	return err
+}
+
+func (o *oplogStore) Delete(ctx context.Context, id int) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, err := o.memoryStore.Get(ctx, id); err != nil {
+		return err
+	}
+	if err := o.appendRecord(oplogRecord{Op: oplogDelete, ID: id}); err != nil {
+		return err
+	}
+	err := o.memoryStore.Delete(ctx, id)
+	o.maybeCompact()
+	return err
+}
+
+func (o *oplogStore) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	defer o.lock.Close()
+	return o.file.Close()
+}
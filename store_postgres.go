@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+var postgresDialect = sqlDialect{
+	name: "postgres",
+	createMigrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	recordMigration: `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+	// An arbitrary key shared by every instance of this service
+	lockMigrations:   `SELECT pg_advisory_lock(7325019)`,
+	unlockMigrations: `SELECT pg_advisory_unlock(7325019)`,
+	insert:           `INSERT INTO students (name, age, email, version, deleted_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+	get:              `SELECT id, name, age, email, version, deleted_at FROM students WHERE id = $1`,
+	list:             `SELECT id, name, age, email, version, deleted_at FROM students ORDER BY id`,
+	update:           `UPDATE students SET name = $1, age = $2, email = $3, version = $4, deleted_at = $5 WHERE id = $6`,
+	delete:           `DELETE FROM students WHERE id = $1`,
+	findByEmail:      `SELECT id, name, age, email, version, deleted_at FROM students WHERE lower(email) = lower($1) AND deleted_at IS NULL ORDER BY id LIMIT 1`,
+}
+
+// openPostgresStore connects to the database at url with the pool limits
+// from c and applies pending migrations
+func openPostgresStore(url string, c Config) (*sqlStore, error) {
+	db, err := connectPostgres(url, c)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	q, err := newSQLStore(ctx, db, postgresDialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+// connectPostgres opens and pings the database at url, without touching
+// its schema
+func connectPostgres(url string, c Config) (*sql.DB, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, fmt.Errorf("open postgres database: %w", err)
+	}
+	db.SetMaxOpenConns(c.DBMaxOpenConns)
+	db.SetMaxIdleConns(c.DBMaxIdleConns)
+	db.SetConnMaxLifetime(c.DBConnMaxLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect to postgres: %w", err)
+	}
+	return db, nil
+}
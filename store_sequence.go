@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// idSequenceBlock is how many IDs are reserved per write of the sequence
+// file. A restart skips the unused rest of the block.
+const idSequenceBlock = 100
+
+// idSequence records in a file how far the memory backend has handed out
+// IDs, so they are never reused after a restart, even when the students
+// themselves are lost. IDs are reserved a block at a time so that creates
+// rarely wait for the disk.
+type idSequence struct {
+	path string
+	// reserved is the first ID not yet covered by the file
+	reserved int
+}
+
+// openIDSequence reads the sequence file at path. A missing file starts
+// the sequence at 1.
+func openIDSequence(path string) (*idSequence, error) {
+	q := &idSequence{path: path, reserved: 1}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read ID sequence: %w", err)
+	}
+	if q.reserved, err = strconv.Atoi(strings.TrimSpace(string(raw))); err != nil || q.reserved < 1 {
+		return nil, fmt.Errorf("ID sequence file %s must hold a positive integer", path)
+	}
+	return q, nil
+}
+
+// reserve makes sure id is covered by the file, reserving the next block
+// if it is not
+func (q *idSequence) reserve(id int) error {
+	if id < q.reserved {
+		return nil
+	}
+	next := id + idSequenceBlock
+	if err := writeFileAtomic(q.path, []byte(strconv.Itoa(next)+"\n")); err != nil {
+		return fmt.Errorf("write ID sequence: %w", err)
+	}
+	q.reserved = next
+	return nil
+}
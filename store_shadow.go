@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// Shadow mode metrics, served on /admin/debug/vars
+var (
+	shadowReads       = expvar.NewInt("shadow_reads_total")
+	shadowMismatches  = expvar.NewInt("shadow_mismatches_total")
+	shadowWriteErrors = expvar.NewInt("shadow_write_errors_total")
+)
+
+// shadowReadTimeout bounds a comparison read, which runs after the request
+const shadowReadTimeout = 5 * time.Second
+
+// shadowStore runs a store migration in shadow mode: every write goes to
+// the primary and then to the shadow, and every read is answered by the
+// primary and repeated against the shadow in the background, logging any
+// difference. Clients only ever see the primary's results.
+//
+// Both stores must assign the same IDs, so the shadow should start empty
+// alongside an empty primary, or hold a copy of it.
+type shadowStore struct {
+	StudentStore
+	shadow StudentStore
+	name   string
+}
+
+// mismatch records a difference between the primary and the shadow
+func (s *shadowStore) mismatch(op, format string, args ...any) {
+	shadowMismatches.Add(1)
+	log.Printf("shadow: %s mismatch against %s: %s", op, s.name, fmt.Sprintf(format, args...))
+}
+
+// writeFailed records a write that succeeded on the primary only
+func (s *shadowStore) writeFailed(op string, err error) {
+	shadowWriteErrors.Add(1)
+	log.Printf("shadow: %s failed on %s: %v", op, s.name, err)
+}
+
+// compare repeats a read against the shadow in the background. want and
+// wantErr are the primary's result and read returns the shadow's.
+func compare[T any](ctx context.Context, s *shadowStore, op string, want T, wantErr error, read func(context.Context) (T, error), equal func(a, b T) bool) {
+	// Detach from the request, which will have finished by the time this runs
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowReadTimeout)
+	go func() {
+		defer cancel()
+		shadowReads.Add(1)
+		got, err := read(ctx)
+		switch {
+		case wantErr != nil || err != nil:
+			// A miss on both sides agrees; any other error is a difference
+			if !errors.Is(wantErr, ErrNotFound) || !errors.Is(err, ErrNotFound) {
+				s.mismatch(op, "primary error %v, shadow error %v", wantErr, err)
+			}
+		case !equal(want, got):
+			s.mismatch(op, "primary %+v, shadow %+v", want, got)
+		}
+	}()
+}
+
+func (s *shadowStore) Create(ctx context.Context, student Student) (Student, error) {
+	created, err := s.StudentStore.Create(ctx, student)
+	if err != nil {
+		return created, err
+	}
+	shadowed, err := s.shadow.Create(ctx, student)
+	switch {
+	case err != nil:
+		s.writeFailed("create", err)
+	case shadowed.ID != created.ID:
+		s.mismatch("create", "primary assigned ID %d, shadow assigned %d", created.ID, shadowed.ID)
+	}
+	return created, nil
+}
+
+// WriteBatch applies b to the primary as one change and then repeats it
+// on the shadow
+func (s *shadowStore) WriteBatch(ctx context.Context, b studentBatch) ([]Student, error) {
+	created, err := writeBatch(ctx, s.StudentStore, b)
+	if err != nil {
+		return created, err
+	}
+	shadowed, err := writeBatch(ctx, s.shadow, b)
+	switch {
+	case err != nil:
+		s.writeFailed("batch", err)
+	case !slices.EqualFunc(created, shadowed, func(a, b Student) bool { return a.ID == b.ID }):
+		s.mismatch("batch", "primary assigned IDs %v, shadow assigned %v", studentIDs(created), studentIDs(shadowed))
+	}
+	return created, nil
+}
+
+// studentIDs lists the IDs of students
+func studentIDs(list []Student) []int {
+	ids := make([]int, len(list))
+	for i, student := range list {
+		ids[i] = student.ID
+	}
+	return ids
+}
+
+func (s *shadowStore) Get(ctx context.Context, id int) (Student, error) {
+	student, err := s.StudentStore.Get(ctx, id)
+	compare(ctx, s, fmt.Sprintf("get %d", id), student, err, func(ctx context.Context) (Student, error) {
+		return s.shadow.Get(ctx, id)
+	}, sameStudent)
+	return student, err
+}
+
+func (s *shadowStore) List(ctx context.Context) ([]Student, error) {
+	list, err := s.StudentStore.List(ctx)
+	// The comparison runs in the background, and callers sort and filter
+	// the list they get in place, so it gets a copy of its own
+	compare(ctx, s, "list", slices.Clone(list), err, s.shadow.List, func(a, b []Student) bool {
+		return slices.EqualFunc(a, b, sameStudent)
+	})
+	return list, err
+}
+
+func (s *shadowStore) Update(ctx context.Context, student Student) error {
+	if err := s.StudentStore.Update(ctx, student); err != nil {
+		return err
+	}
+	if err := s.shadow.Update(ctx, student); err != nil {
+		s.writeFailed(fmt.Sprintf("update %d", student.ID), err)
+	}
+	return nil
+}
+
+func (s *shadowStore) Delete(ctx context.Context, id int) error {
+	if err := s.StudentStore.Delete(ctx, id); err != nil {
+		return err
+	}
+	if err := s.shadow.Delete(ctx, id); err != nil {
+		s.writeFailed(fmt.Sprintf("delete %d", id), err)
+	}
+	return nil
+}
+
+func (s *shadowStore) FindByEmail(ctx context.Context, email string) (Student, error) {
+	student, err := s.StudentStore.FindByEmail(ctx, email)
+	compare(ctx, s, "find by email", student, err, func(ctx context.Context) (Student, error) {
+		return s.shadow.FindByEmail(ctx, email)
+	}, sameStudent)
+	return student, err
+}
+
+func (s *shadowStore) Close() error {
+	shadowErr := s.shadow.Close()
+	if err := s.StudentStore.Close(); err != nil {
+		return err
+	}
+	return shadowErr
+}
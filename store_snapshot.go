@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// snapshotStore is a memoryStore that is written to a file every interval
+// and once more on Close, and reloaded from it on startup. Unlike the file
+// backend, writes do not wait for the disk, so a crash loses at most the
+// changes of the last interval.
+type snapshotStore struct {
+	*memoryStore
+	path string
+	lock *os.File
+
+	stop chan struct{}
+	done chan struct{}
+
+	// mu serializes snapshot writes; last is the most recent one written
+	mu   sync.Mutex
+	last fileSnapshot
+}
+
+// openSnapshotStore loads the snapshot at path, if any, and starts writing
+// a new one every interval
+func openSnapshotStore(path string, limit int, interval time.Duration) (*snapshotStore, error) {
+	lock, err := lockDataFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := readSnapshotFile(path)
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	s := &snapshotStore{
+		memoryStore: newMemoryStore(limit),
+		path:        path,
+		lock:        lock,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		last:        snap,
+	}
+	s.restore(snap)
+	if len(snap.Students) > 0 {
+		log.Printf("snapshot: restored %d student(s) from %s", len(snap.Students), path)
+	}
+
+	go s.run(interval)
+	return s, nil
+}
+
+// run writes a snapshot every interval until Close
+func (s *snapshotStore) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.save(); err != nil {
+				log.Printf("snapshot: %v", err)
+			}
+		}
+	}
+}
+
+// save writes the students to the snapshot file if they changed since the
+// last snapshot
+func (s *snapshotStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := s.snapshot()
+	if snap.NextID == s.last.NextID && slices.EqualFunc(snap.Students, s.last.Students, sameStudent) {
+		return nil
+	}
+	if err := writeSnapshotFile(s.path, snap); err != nil {
+		return err
+	}
+	s.last = snap
+	return nil
+}
+
+// Close stops the periodic snapshots and writes a final one
+func (s *snapshotStore) Close() error {
+	close(s.stop)
+	<-s.done
+	defer s.lock.Close()
+	return s.save()
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sqlDialect holds the statements for one SQL database. Its schema comes
+// from the embedded migrations named after it. Every query selects the
+// columns id, name, age, email, version, deleted_at in that order.
+type sqlDialect struct {
+	name string
+
+	createMigrationsTable string
+	// recordMigration inserts a version and name into schema_migrations
+	recordMigration string
+	// lockMigrations and unlockMigrations, if set, keep other instances
+	// from migrating at the same time
+	lockMigrations   string
+	unlockMigrations string
+	// lockReturnsResult is set when lockMigrations selects 1 once the lock
+	// is held rather than waiting for it indefinitely
+	lockReturnsResult bool
+	// repeatedDDL, if set, recognizes the error a schema change gives when
+	// it was already made. Databases whose DDL commits on its own, like
+	// MySQL, need it: a migration that failed after its ALTER leaves the
+	// change behind without the schema_migrations row, and the retry must
+	// skip it.
+	repeatedDDL func(error) bool
+
+	// insert returns the new ID either through RETURNING id or, when
+	// lastInsertID is set, through sql.Result.LastInsertId
+	insert       string
+	lastInsertID bool
+	get          string
+	list         string
+	update       string
+	delete       string
+	findByEmail  string
+}
+
+// sqlStore is a StudentStore over database/sql. Its statements are prepared
+// once and reused across the connection pool.
+type sqlStore struct {
+	db      *sql.DB
+	dialect sqlDialect
+
+	insert, get, list, update, delete, findByEmail *sql.Stmt
+}
+
+// newSQLStore applies pending migrations and prepares the dialect's statements
+func newSQLStore(ctx context.Context, db *sql.DB, d sqlDialect) (*sqlStore, error) {
+	if err := migrate(ctx, db, d); err != nil {
+		return nil, err
+	}
+
+	q := &sqlStore{db: db, dialect: d}
+	for _, p := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&q.insert, d.insert},
+		{&q.get, d.get},
+		{&q.list, d.list},
+		{&q.update, d.update},
+		{&q.delete, d.delete},
+		{&q.findByEmail, d.findByEmail},
+	} {
+		stmt, err := db.PrepareContext(ctx, p.query)
+		if err != nil {
+			q.closeStatements()
+			return nil, fmt.Errorf("prepare %s statement: %w", d.name, err)
+		}
+		*p.stmt = stmt
+	}
+	return q, nil
+}
+
+// scanStudent reads one students row
+func scanStudent(row interface{ Scan(...any) error }) (Student, error) {
+	var s Student
+	var deletedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.Name, &s.Age, &s.Email, &s.Version, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Student{}, ErrNotFound
+	}
+	if deletedAt.Valid {
+		t := deletedAt.Time.UTC()
+		s.DeletedAt = &t
+	}
+	return s, err
+}
+
+// nullTime converts an optional time for a query argument
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func (q *sqlStore) Create(ctx context.Context, s Student) (Student, error) {
+	if !q.dialect.lastInsertID {
+		if err := q.insert.QueryRowContext(ctx, s.Name, s.Age, s.Email, s.Version, nullTime(s.DeletedAt)).Scan(&s.ID); err != nil {
+			return Student{}, err
+		}
+		return s, nil
+	}
+
+	res, err := q.insert.ExecContext(ctx, s.Name, s.Age, s.Email, s.Version, nullTime(s.DeletedAt))
+	if err != nil {
+		return Student{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Student{}, err
+	}
+	s.ID = int(id)
+	return s, nil
+}
+
+// WriteBatch applies b in one database transaction
+func (q *sqlStore) WriteBatch(ctx context.Context, b studentBatch) ([]Student, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	update := tx.StmtContext(ctx, q.update)
+	for _, s := range b.updates {
+		res, err := update.ExecContext(ctx, s.Name, s.Age, s.Email, s.Version, nullTime(s.DeletedAt), s.ID)
+		if err == nil {
+			err = requireAffected(res)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("update student %d: %w", s.ID, err)
+		}
+	}
+
+	insert := tx.StmtContext(ctx, q.insert)
+	created := make([]Student, 0, len(b.creates))
+	for _, s := range b.creates {
+		if !q.dialect.lastInsertID {
+			if err := insert.QueryRowContext(ctx, s.Name, s.Age, s.Email, s.Version, nullTime(s.DeletedAt)).Scan(&s.ID); err != nil {
+				return nil, err
+			}
+		} else {
+			res, err := insert.ExecContext(ctx, s.Name, s.Age, s.Email, s.Version, nullTime(s.DeletedAt))
+			if err != nil {
+				return nil, err
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				return nil, err
+			}
+			s.ID = int(id)
+		}
+		created = append(created, s)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (q *sqlStore) Get(ctx context.Context, id int) (Student, error) {
+	return scanStudent(q.get.QueryRowContext(ctx, id))
+}
+
+func (q *sqlStore) List(ctx context.Context) ([]Student, error) {
+	rows, err := q.list.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Student
+	for rows.Next() {
+		s, err := scanStudent(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+func (q *sqlStore) Update(ctx context.Context, s Student) error {
+	res, err := q.update.ExecContext(ctx, s.Name, s.Age, s.Email, s.Version, nullTime(s.DeletedAt), s.ID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+func (q *sqlStore) Delete(ctx context.Context, id int) error {
+	res, err := q.delete.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+func (q *sqlStore) FindByEmail(ctx context.Context, email string) (Student, error) {
+	return scanStudent(q.findByEmail.QueryRowContext(ctx, email))
+}
+
+func (q *sqlStore) Ping(ctx context.Context) error {
+	return q.db.PingContext(ctx)
+}
+
+func (q *sqlStore) Close() error {
+	q.closeStatements()
+	return q.db.Close()
+}
+
+// closeStatements releases the prepared statements that were created
+func (q *sqlStore) closeStatements() {
+	for _, stmt := range []*sql.Stmt{q.insert, q.get, q.list, q.update, q.delete, q.findByEmail} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+// requireAffected turns an update or delete that matched no row into ErrNotFound
+func requireAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var sqliteDialect = sqlDialect{
+	name: "sqlite",
+	createMigrationsTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	recordMigration: `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`,
+	insert:          `INSERT INTO students (name, age, email, version, deleted_at) VALUES (?, ?, ?, ?, ?)`,
+	lastInsertID:    true,
+	get:             `SELECT id, name, age, email, version, deleted_at FROM students WHERE id = ?`,
+	list:            `SELECT id, name, age, email, version, deleted_at FROM students ORDER BY id`,
+	update:          `UPDATE students SET name = ?, age = ?, email = ?, version = ?, deleted_at = ? WHERE id = ?`,
+	delete:          `DELETE FROM students WHERE id = ?`,
+	findByEmail:     `SELECT id, name, age, email, version, deleted_at FROM students WHERE email = ? COLLATE NOCASE AND deleted_at IS NULL ORDER BY id LIMIT 1`,
+}
+
+// openSQLiteStore opens (creating if needed) the database at path
+func openSQLiteStore(path string) (*sqlStore, error) {
+	// WAL lets reads proceed during writes; the busy timeout makes concurrent
+	// writers wait for the lock instead of failing
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	q, err := newSQLStore(context.Background(), db, sqliteDialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return q, nil
+}
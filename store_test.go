@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestStoreConformance runs the StudentStore and batch write contract
+// against the memory and sqlite backends, and against MySQL and Postgres
+// when MYSQL_TEST_DSN and POSTGRES_TEST_DSN point at a database. Those
+// databases are emptied first, so never point them at one that matters.
+func TestStoreConformance(t *testing.T) {
+	backends := []struct {
+		name string
+		env  string
+		open func(t *testing.T) (StudentStore, error)
+	}{
+		{name: "memory", open: func(t *testing.T) (StudentStore, error) {
+			return newMemoryStore(100), nil
+		}},
+		{name: "sqlite", open: func(t *testing.T) (StudentStore, error) {
+			return openSQLiteStore(filepath.Join(t.TempDir(), "students.db"))
+		}},
+		{name: "mysql", env: "MYSQL_TEST_DSN", open: func(t *testing.T) (StudentStore, error) {
+			return openMySQLStore(os.Getenv("MYSQL_TEST_DSN"), Config{})
+		}},
+		{name: "postgres", env: "POSTGRES_TEST_DSN", open: func(t *testing.T) (StudentStore, error) {
+			return openPostgresStore(os.Getenv("POSTGRES_TEST_DSN"), Config{})
+		}},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			if backend.env != "" && os.Getenv(backend.env) == "" {
+				t.Skipf("set %s to run against %s", backend.env, backend.name)
+			}
+			store, err := backend.open(t)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			emptyStore(t, store)
+
+			t.Run("crud", func(t *testing.T) { testStoreCRUD(t, store) })
+			emptyStore(t, store)
+			t.Run("batch", func(t *testing.T) { testStoreBatch(t, store) })
+		})
+	}
+}
+
+// emptyStore deletes every student in store
+func emptyStore(t *testing.T, store StudentStore) {
+	t.Helper()
+	ctx := context.Background()
+	list, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	for _, s := range list {
+		if err := store.Delete(ctx, s.ID); err != nil {
+			t.Fatalf("delete %d: %v", s.ID, err)
+		}
+	}
+}
+
+func testStoreCRUD(t *testing.T, store StudentStore) {
+	ctx := context.Background()
+	if err := store.Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	ada, err := store.Create(ctx, Student{Name: "Ada", Age: 20, Email: "ada@example.com", Version: 1})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	bob, err := store.Create(ctx, Student{Name: "Bob", Age: 21, Email: "bob@example.com", Version: 1})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if ada.ID <= 0 || bob.ID <= ada.ID {
+		t.Fatalf("create assigned IDs %d and %d, want increasing positive IDs", ada.ID, bob.ID)
+	}
+
+	got, err := store.Get(ctx, ada.ID)
+	if err != nil || !sameStudent(got, ada) {
+		t.Fatalf("get %d = %+v, %v; want %+v", ada.ID, got, err, ada)
+	}
+	if _, err := store.Get(ctx, bob.ID+1000); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing = %v, want ErrNotFound", err)
+	}
+
+	list, err := store.List(ctx)
+	if err != nil || len(list) != 2 || list[0].ID != ada.ID || list[1].ID != bob.ID {
+		t.Fatalf("list = %+v, %v; want Ada then Bob", list, err)
+	}
+
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+	ada.Name, ada.Version, ada.DeletedAt = "Ada L.", 2, &deletedAt
+	if err := store.Update(ctx, ada); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, err := store.Get(ctx, ada.ID); err != nil || !sameStudent(got, ada) {
+		t.Fatalf("get after update = %+v, %v; want %+v", got, err, ada)
+	}
+	if err := store.Update(ctx, Student{ID: bob.ID + 1000, Name: "Nobody", Age: 1, Email: "x@example.com"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("update missing = %v, want ErrNotFound", err)
+	}
+
+	// FindByEmail ignores case and soft-deleted students
+	if got, err := store.FindByEmail(ctx, "BOB@example.com"); err != nil || got.ID != bob.ID {
+		t.Fatalf("find bob = %+v, %v; want student %d", got, err, bob.ID)
+	}
+	if _, err := store.FindByEmail(ctx, "ada@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("find soft-deleted ada = %v, want ErrNotFound", err)
+	}
+
+	if err := store.Delete(ctx, bob.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.Delete(ctx, bob.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete twice = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Get(ctx, bob.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get deleted = %v, want ErrNotFound", err)
+	}
+}
+
+func testStoreBatch(t *testing.T, store StudentStore) {
+	ctx := context.Background()
+	ada, err := store.Create(ctx, Student{Name: "Ada", Age: 20, Email: "ada@example.com", Version: 1})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	changed := ada
+	changed.Name, changed.Version = "Ada L.", 2
+	created, err := writeBatch(ctx, store, studentBatch{
+		updates: []Student{changed},
+		before:  []Student{ada},
+		creates: []Student{
+			{Name: "Bob", Age: 21, Email: "bob@example.com", Version: 1},
+			{Name: "Cy", Age: 22, Email: "cy@example.com", Version: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("write batch: %v", err)
+	}
+	if len(created) != 2 || created[0].ID <= ada.ID || created[1].ID <= created[0].ID {
+		t.Fatalf("batch created %+v, want two new students with increasing IDs", created)
+	}
+	list, err := store.List(ctx)
+	if err != nil || len(list) != 3 || !sameStudent(list[0], changed) || !sameStudent(list[1], created[0]) || !sameStudent(list[2], created[1]) {
+		t.Fatalf("list after batch = %+v, %v", list, err)
+	}
+
+	// A batch with one bad write leaves the store as it was
+	again := changed
+	again.Name, again.Version = "Ada Lovelace", 3
+	_, err = writeBatch(ctx, store, studentBatch{
+		updates: []Student{again, {ID: created[1].ID + 1000, Name: "Nobody", Age: 1, Email: "x@example.com"}},
+		before:  []Student{changed, {}},
+		creates: []Student{{Name: "Dee", Age: 23, Email: "dee@example.com", Version: 1}},
+	})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("failed batch = %v, want ErrNotFound", err)
+	}
+	after, err := store.List(ctx)
+	if err != nil || len(after) != len(list) {
+		t.Fatalf("list after failed batch = %+v, %v; want %+v", after, err, list)
+	}
+	for i := range list {
+		if !sameStudent(after[i], list[i]) {
+			t.Fatalf("failed batch left %+v, want %+v", after[i], list[i])
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Typeahead responses are capped so they stay small and fast
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 20
+)
+
+// suggestIndexMaxAge is how old the prefix index may get before it is
+// rebuilt even if this process made no writes, so changes made through
+// other replicas sharing the store show up. Clients cache responses for
+// as long.
+const suggestIndexMaxAge = 30 * time.Second
+
+// suggestEntry maps one lowercased searchable term to a student
+type suggestEntry struct {
+	term    string
+	student Student
+}
+
+// invalidateSuggestIndex marks the prefix index stale. The caller must hold s.mu.
+func (s *server) invalidateSuggestIndex() {
+	s.suggestIndexStale = true
+}
+
+// rebuildSuggestIndex rebuilds the prefix index from the store if it is
+// stale or older than suggestIndexMaxAge. The caller must hold s.mu.
+func (s *server) rebuildSuggestIndex(ctx context.Context) error {
+	if !s.suggestIndexStale && time.Since(s.suggestIndexBuilt) < suggestIndexMaxAge {
+		return nil
+	}
+
+	list, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.suggestIndex = s.suggestIndex[:0]
+	for _, student := range liveStudents(list) {
+		name := strings.ToLower(student.Name)
+		terms := append(strings.Fields(name), name, strings.ToLower(student.Email))
+		for _, term := range terms {
+			s.suggestIndex = append(s.suggestIndex, suggestEntry{term: term, student: student})
+		}
+	}
+	sort.Slice(s.suggestIndex, func(i, j int) bool {
+		if s.suggestIndex[i].term != s.suggestIndex[j].term {
+			return s.suggestIndex[i].term < s.suggestIndex[j].term
+		}
+		return s.suggestIndex[i].student.ID < s.suggestIndex[j].student.ID
+	})
+	s.suggestIndexStale = false
+	s.suggestIndexBuilt = time.Now()
+	return nil
+}
+
+// suggestStudents handles GET /students/suggest
+func (s *server) suggestStudents(c *gin.Context) {
+	query := strings.ToLower(strings.TrimSpace(c.Query("q")))
+
+	limit := defaultSuggestLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = min(n, maxSuggestLimit)
+	}
+
+	type suggestion struct {
+		ID    any    `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	suggestions := []suggestion{}
+
+	if query != "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := s.rebuildSuggestIndex(c.Request.Context()); err != nil {
+			respondStoreError(c, err)
+			return
+		}
+
+		seen := map[int]bool{}
+		start := sort.Search(len(s.suggestIndex), func(i int) bool { return s.suggestIndex[i].term >= query })
+		for i := start; i < len(s.suggestIndex) && len(suggestions) < limit; i++ {
+			entry := s.suggestIndex[i]
+			if !strings.HasPrefix(entry.term, query) {
+				break
+			}
+			if seen[entry.student.ID] {
+				continue
+			}
+			seen[entry.student.ID] = true
+			suggestions = append(suggestions, suggestion{ID: publicID(entry.student.ID), Name: entry.student.Name, Email: entry.student.Email})
+		}
+	}
+
+	// Typeahead fires on every keystroke; let the browser reuse recent answers
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(suggestIndexMaxAge.Seconds())))
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
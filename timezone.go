@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// Embedded so TIMEZONE works on hosts without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// displayZone is the TIMEZONE that responses give times in. Times are
+// stored in UTC whatever it is.
+var displayZone = time.UTC
+
+// localTime converts a stored time for a response
+func localTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	local := t.In(displayZone)
+	return &local
+}
+
+// clockWindow is a daily time range such as 02:00-05:00, in minutes since
+// midnight. It may wrap past midnight, as in 22:00-04:00.
+type clockWindow struct {
+	start, end int
+}
+
+// parseClockWindow parses a HH:MM-HH:MM range
+func parseClockWindow(raw string) (clockWindow, error) {
+	from, to, ok := strings.Cut(raw, "-")
+	if !ok {
+		return clockWindow{}, fmt.Errorf("want HH:MM-HH:MM (got %q)", raw)
+	}
+	var w clockWindow
+	for _, part := range []struct {
+		raw string
+		dst *int
+	}{{from, &w.start}, {to, &w.end}} {
+		t, err := time.Parse("15:04", strings.TrimSpace(part.raw))
+		if err != nil {
+			return clockWindow{}, fmt.Errorf("want HH:MM-HH:MM (got %q)", raw)
+		}
+		*part.dst = t.Hour()*60 + t.Minute()
+	}
+	if w.start == w.end {
+		return clockWindow{}, fmt.Errorf("window %q is empty", raw)
+	}
+	return w, nil
+}
+
+// contains reports whether t, in zone, falls inside the window
+func (w clockWindow) contains(t time.Time, zone *time.Location) bool {
+	local := t.In(zone)
+	minute := local.Hour()*60 + local.Minute()
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// String formats the window as HH:MM-HH:MM
+func (w clockWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.start/60, w.start%60, w.end/60, w.end%60)
+}
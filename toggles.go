@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Access log levels
+const (
+	accessLogAll    = "all"
+	accessLogErrors = "errors"
+	accessLogOff    = "off"
+)
+
+// toggles are the settings that can be changed through the admin API while
+// the service runs. Changes last until the next restart.
+type toggles struct {
+	accessLog atomic.Value // string
+	// replayPaused stops the replay recorder, if there is one, from
+	// capturing request bodies
+	replayPaused atomic.Bool
+}
+
+// newToggles starts the toggles at their configured values
+func newToggles(c Config) *toggles {
+	t := &toggles{}
+	t.accessLog.Store(c.AccessLog)
+	return t
+}
+
+// logger is the access log, writing the requests that ACCESS_LOG, or the
+// level set since, asks for
+func (t *toggles) logger() gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{
+		Skip: func(c *gin.Context) bool {
+			switch t.accessLog.Load().(string) {
+			case accessLogOff:
+				return true
+			case accessLogErrors:
+				return c.Writer.Status() < http.StatusBadRequest
+			}
+			return false
+		},
+	})
+}
+
+// toggleSettings is the body of PATCH /admin/toggles and the response of
+// both toggle endpoints
+type toggleSettings struct {
+	GinMode         *string `json:"gin_mode,omitempty"`
+	AccessLog       *string `json:"access_log,omitempty"`
+	ReplayRecording *bool   `json:"replay_recording,omitempty"`
+}
+
+// String lists the toggles that are set, for the log
+func (t toggleSettings) String() string {
+	var parts []string
+	if t.GinMode != nil {
+		parts = append(parts, "gin_mode="+*t.GinMode)
+	}
+	if t.AccessLog != nil {
+		parts = append(parts, "access_log="+*t.AccessLog)
+	}
+	if t.ReplayRecording != nil {
+		parts = append(parts, fmt.Sprintf("replay_recording=%t", *t.ReplayRecording))
+	}
+	return strings.Join(parts, " ")
+}
+
+// currentToggles reports the toggles. replay_recording only appears when
+// REPLAY_LOG is set.
+func (s *server) currentToggles() toggleSettings {
+	mode := gin.Mode()
+	accessLog := s.toggles.accessLog.Load().(string)
+	settings := toggleSettings{GinMode: &mode, AccessLog: &accessLog}
+	if s.cfg.ReplayLog != "" {
+		recording := !s.toggles.replayPaused.Load()
+		settings.ReplayRecording = &recording
+	}
+	return settings
+}
+
+// getToggles handles GET /admin/toggles
+func (s *server) getToggles(c *gin.Context) {
+	c.JSON(http.StatusOK, s.currentToggles())
+}
+
+// setToggles handles PATCH /admin/toggles, changing the toggles in the body
+// and leaving the others as they are
+func (s *server) setToggles(c *gin.Context) {
+	var body toggleSettings
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if body.GinMode != nil {
+		switch *body.GinMode {
+		case gin.DebugMode, gin.ReleaseMode:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "gin_mode must be debug or release"})
+			return
+		}
+	}
+	if body.AccessLog != nil && !validAccessLog(*body.AccessLog) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "access_log must be all, errors or off"})
+		return
+	}
+	if body.ReplayRecording != nil && s.cfg.ReplayLog == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "replay_recording needs REPLAY_LOG to be set"})
+		return
+	}
+
+	before := s.currentToggles()
+	if body.GinMode != nil {
+		gin.SetMode(*body.GinMode)
+	}
+	if body.AccessLog != nil {
+		s.toggles.accessLog.Store(*body.AccessLog)
+	}
+	if body.ReplayRecording != nil {
+		s.toggles.replayPaused.Store(!*body.ReplayRecording)
+	}
+	after := s.currentToggles()
+	log.Printf("admin: toggles changed from %v to %v", before, after)
+	c.JSON(http.StatusOK, after)
+}
+
+// validAccessLog reports whether level is an access log level
+func validAccessLog(level string) bool {
+	switch level {
+	case accessLogAll, accessLogErrors, accessLogOff:
+		return true
+	}
+	return false
+}
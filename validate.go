@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// checkTimeout bounds each connectivity check run by config validate
+const checkTimeout = 5 * time.Second
+
+// checkResult is the outcome of one validation step
+type checkResult struct {
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+
+	// err is the error behind Error when the check could not connect, so
+	// startup can tell whether retrying may help
+	err error
+}
+
+// validationReport is the machine-readable output of config validate
+type validationReport struct {
+	Valid    bool              `json:"valid"`
+	Settings map[string]string `json:"settings,omitempty"`
+	Checks   []checkResult     `json:"checks"`
+}
+
+// runConfigCommand implements the config subcommand and returns the exit code
+func runConfigCommand(args []string) int {
+	if len(args) != 1 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: config validate")
+		return 2
+	}
+
+	report := validationReport{Valid: true}
+	loaded, err := loadConfig()
+	if err != nil {
+		report.Valid = false
+		report.Checks = append(report.Checks, checkResult{Name: "config", Error: err.Error()})
+	} else {
+		report.Settings = loaded.settings()
+		report.Checks = append(report.Checks, checkResult{Name: "config", OK: true})
+		report.Checks = append(report.Checks, checkStore("store", loaded.StoreBackend, loaded))
+		if loaded.ShadowBackend != "" {
+			report.Checks = append(report.Checks, checkStore("shadow_store", loaded.ShadowBackend, loaded))
+		}
+		if loaded.RedisURL != "" {
+			report.Checks = append(report.Checks, checkRedis(loaded))
+		}
+		report.Checks = append(report.Checks, checkOllama(loaded))
+	}
+
+	for _, check := range report.Checks {
+		report.Valid = report.Valid && check.OK
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	if err := out.Encode(report); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
+
+// checkOllama verifies Ollama is reachable and has the configured model,
+// using the read-only model listing endpoint
+func checkOllama(c Config) checkResult {
+	result := checkResult{Name: "ollama"}
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.OllamaURL+"/api/tags", nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	result.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		result.err = err
+		return result
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		result.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+		return result
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		result.Error = fmt.Sprintf("invalid response: %v", err)
+		return result
+	}
+
+	// Ollama reports models with their tag, e.g. llama2:latest
+	for _, model := range tags.Models {
+		if model.Name == c.OllamaModel || strings.TrimSuffix(model.Name, ":latest") == c.OllamaModel {
+			result.OK = true
+			return result
+		}
+	}
+	result.Error = fmt.Sprintf("model %q is not available on this Ollama instance", c.OllamaModel)
+	return result
+}
+
+// checkStore verifies the named backend is reachable. It connects and
+// pings without running migrations or creating tables, and for the
+// backends kept in local files only checks that their directory exists.
+func checkStore(name, backend string, c Config) checkResult {
+	result := checkResult{Name: name}
+	start := time.Now()
+	err := pingBackend(backend, c)
+	result.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.OK = true
+	return result
+}
+
+// pingBackend connects to backend and closes the connection again
+func pingBackend(backend string, c Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	switch backend {
+	case "memory":
+		if c.SnapshotInterval > 0 {
+			return checkDataDir(c.SnapshotPath)
+		}
+		return nil
+	case "file":
+		return checkDataDir(c.FilePath)
+	case "oplog":
+		return checkDataDir(c.OplogPath)
+	case "bolt":
+		return checkDataDir(c.BoltPath)
+	case "sqlite":
+		return checkDataDir(c.SQLitePath)
+	case "postgres":
+		db, err := connectPostgres(c.DatabaseURL, c)
+		if err != nil {
+			return err
+		}
+		return db.Close()
+	case "mysql":
+		db, err := connectMySQL(c.MySQLDSN, c)
+		if err != nil {
+			return err
+		}
+		return db.Close()
+	case "mongodb":
+		client, err := connectMongo(c.MongoURI)
+		if err != nil {
+			return err
+		}
+		return client.Disconnect(ctx)
+	case "dynamodb":
+		d, err := newDynamoStore(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err := d.Ping(ctx); err != nil {
+			return fmt.Errorf("describe dynamodb table %s: %w", c.DynamoTable, err)
+		}
+		return nil
+	case "etcd":
+		e, err := openEtcdStore(c)
+		if err != nil {
+			return err
+		}
+		return e.Close()
+	default:
+		return fmt.Errorf("unknown store backend %q", backend)
+	}
+}
+
+// checkDataDir verifies the directory a data file goes in exists. The file
+// itself may not, as the backends create it on first start.
+func checkDataDir(path string) error {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("data directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("data directory %s is not a directory", dir)
+	}
+	return nil
+}
+
+// checkRedis verifies the Redis at REDIS_URL answers a PING
+func checkRedis(c Config) checkResult {
+	result := checkResult{Name: "redis"}
+	opts, err := redis.ParseURL(c.RedisURL)
+	if err != nil {
+		result.Error = fmt.Sprintf("parse REDIS_URL: %v", err)
+		return result
+	}
+	client := redis.NewClient(opts)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	start := time.Now()
+	err = client.Ping(ctx).Err()
+	result.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.OK = true
+	return result
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Build information, set at build time with
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// When they are not set, the commit and date recorded by the Go toolchain
+// for builds inside a git checkout are used instead.
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// versionHeader carries the running version on every response, to tell
+// replicas apart during a rollout
+const versionHeader = "X-App-Version"
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && commit == "":
+			commit = setting.Value
+			if len(commit) > 12 {
+				commit = commit[:12]
+			}
+		case setting.Key == "vcs.time" && buildDate == "":
+			buildDate = setting.Value
+		}
+	}
+}
+
+// buildInfo describes the running binary
+func buildInfo() gin.H {
+	return gin.H{
+		"version":    version,
+		"commit":     orUnknown(commit),
+		"build_date": orUnknown(buildDate),
+		"go_version": runtime.Version(),
+	}
+}
+
+// orUnknown substitutes "unknown" for build information that was not recorded
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// startupBanner is logged once the service is configured
+func startupBanner(c Config) string {
+	return fmt.Sprintf("starting students API version=%s commit=%s build_date=%s go=%s addr=%s region=%s store=%s",
+		version, orUnknown(commit), orUnknown(buildDate), runtime.Version(), c.Addr, orUnknown(c.Region), c.StoreBackend)
+}
+
+// versionMiddleware adds the X-App-Version header to every response
+func versionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header(versionHeader, version)
+		c.Next()
+	}
+}
+
+// getVersion handles GET /version
+func getVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, buildInfo())
+}
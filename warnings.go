@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ValidationLevel controls what happens to input that is valid but unusual
+type ValidationLevel string
+
+const (
+	// ValidationOff accepts it silently
+	ValidationOff ValidationLevel = "off"
+	// ValidationWarn accepts it and lists the warnings in the response
+	ValidationWarn ValidationLevel = "warn"
+	// ValidationStrict refuses it with 400 Bad Request
+	ValidationStrict ValidationLevel = "strict"
+)
+
+// Ages outside this range are accepted but warned about
+const (
+	minUsualAge = 10
+	maxUsualAge = 80
+)
+
+// freeMailDomains are consumer mail providers; schools usually give
+// students an address at their own domain
+var freeMailDomains = map[string]bool{
+	"aol.com":        true,
+	"gmail.com":      true,
+	"gmx.com":        true,
+	"googlemail.com": true,
+	"hotmail.com":    true,
+	"icloud.com":     true,
+	"live.com":       true,
+	"mail.com":       true,
+	"outlook.com":    true,
+	"proton.me":      true,
+	"protonmail.com": true,
+	"yahoo.com":      true,
+	"yandex.com":     true,
+}
+
+// studentWarnings lists what is unusual about a student that passed
+// validation
+func studentWarnings(s Student) []string {
+	var warnings []string
+	if s.Age < minUsualAge || s.Age > maxUsualAge {
+		warnings = append(warnings, "age is unusual for a student")
+	}
+	if strings.TrimSpace(s.Name) != s.Name {
+		warnings = append(warnings, "name has leading or trailing spaces")
+	}
+	_, domain, ok := strings.Cut(s.Email, "@")
+	switch {
+	case !ok || !strings.Contains(domain, "."):
+		warnings = append(warnings, "email does not look like an address")
+	case freeMailDomains[strings.ToLower(domain)]:
+		warnings = append(warnings, "email is at a free mail provider rather than a school domain")
+	}
+	return warnings
+}
+
+// validateStudent checks a student about to be stored: the required
+// fields, and then VALIDATION_LEVEL. It returns the warnings to report, or
+// the problem that refuses the student. Every endpoint that stores
+// students goes through it.
+func (s *server) validateStudent(student Student) (warnings []string, problem string) {
+	if student.Name == "" || student.Age <= 0 || student.Email == "" {
+		return nil, "Invalid input data"
+	}
+	if s.cfg.ValidationLevel == ValidationOff {
+		return nil, ""
+	}
+	warnings = studentWarnings(student)
+	if s.cfg.ValidationLevel == ValidationStrict && len(warnings) > 0 {
+		return warnings, "Invalid input data: " + strings.Join(warnings, ", ")
+	}
+	return warnings, ""
+}
+
+// checkWarnings validates student for a single-student endpoint. It
+// returns the warnings to include in the response, or false after
+// answering 400 when the student is refused.
+func (s *server) checkWarnings(c *gin.Context, student Student) ([]string, bool) {
+	warnings, problem := s.validateStudent(student)
+	if problem != "" {
+		c.JSON(http.StatusBadRequest, withWarnings(gin.H{"error": "Invalid input data"}, warnings))
+		return nil, false
+	}
+	return warnings, true
+}
+
+// withWarnings adds the warnings, if any, to a response body
+func withWarnings(body gin.H, warnings []string) gin.H {
+	if len(warnings) > 0 {
+		body["warnings"] = warnings
+	}
+	return body
+}